@@ -1,13 +1,91 @@
 package allegory
 
+import (
+	"math"
+)
+
 type Actor struct {
-	X, Y, xspeed, yspeed float32
+	X, Y          float32
 	Width, Height int
+
+	// Angle is the actor's rotation in radians.
+	Angle float32
+
+	// ScaleX and ScaleY are the actor's horizontal and vertical
+	// scale factors. A value of 0 is treated as 1.
+	ScaleX, ScaleY float32
+
+	prev    Transform // the transform at the start of the current step
+	stepped bool      // has prev been recorded?
+	snapped bool      // should interpolation be skipped until the next step?
+}
+
+// Transform is a snapshot of an actor's position, rotation and scale.
+type Transform struct {
+	X, Y, Angle, ScaleX, ScaleY float32
+}
+
+func (a *Actor) Move(x, y float32) { a.X += x; a.Y += y }
+
+// Teleport() moves the actor directly to a new position. Unlike
+// changing X and Y, it won't be drawn sliding there on the next frame.
+func (a *Actor) Teleport(x, y float32) {
+	a.X, a.Y = x, y
+	a.Snap()
+}
+
+// Snap() turns off interpolation until the next step, so that any
+// changes made to the actor's transform during this one take effect
+// immediately when rendering.
+func (a *Actor) Snap() { a.snapped = true }
+
+// Transform() returns the actor's current transform.
+func (a *Actor) Transform() Transform {
+	t := Transform{a.X, a.Y, a.Angle, a.ScaleX, a.ScaleY}
+	if t.ScaleX == 0 {
+		t.ScaleX = 1
+	}
+	if t.ScaleY == 0 {
+		t.ScaleY = 1
+	}
+	return t
 }
 
-func (a *Actor) Move(x, y float32) { a.X += x; a.Y += y; a.xspeed, a.yspeed = x, y }
+// Interpolate() returns the actor's transform as it should be drawn,
+// part of the way between the previous step and the current one.
+// delta is the value passed to Render().
+func (a *Actor) Interpolate(delta float32) Transform {
+	cur := a.Transform()
+	if !a.stepped || a.snapped {
+		return cur
+	}
+	prev := a.prev
+	turn := float32(math.Remainder(float64(cur.Angle-prev.Angle), 2*math.Pi))
+	return Transform{
+		X:      prev.X + (cur.X-prev.X)*delta,
+		Y:      prev.Y + (cur.Y-prev.Y)*delta,
+		Angle:  prev.Angle + turn*delta,
+		ScaleX: prev.ScaleX + (cur.ScaleX-prev.ScaleX)*delta,
+		ScaleY: prev.ScaleY + (cur.ScaleY-prev.ScaleY)*delta,
+	}
+}
+
+// CalculatePos() returns the interpolated position of the actor.
 func (a *Actor) CalculatePos(delta float32) (x, y float32) {
-	return a.X + (a.xspeed * delta), a.Y + (a.yspeed * delta)
+	t := a.Interpolate(delta)
+	return t.X, t.Y
+}
+
+// Bounds() returns the area of the world covered by the actor.
+func (a *Actor) Bounds() Rect {
+	return Rect{a.X, a.Y, float32(a.Width), float32(a.Height)}
+}
+
+// snapshot() records the actor's transform at the start of a step.
+func (a *Actor) snapshot() {
+	a.prev = a.Transform()
+	a.stepped = true
+	a.snapped = false
 }
 
 /* -- Related methods -- */
@@ -18,8 +96,9 @@ func AddActor(layer uint, actor, state interface{}) {
 		return
 	}
 	_actors[cur] = append(_actors[cur], actor)
+	_actorLayer[actor] = layer
 	if l, ok := _actorLayers[cur][layer]; ok {
-		l = append(l, actor)
+		_actorLayers[cur][layer] = append(l, actor)
 	} else {
 		_actorLayers[cur][layer] = []interface{}{actor}
 	}
@@ -81,7 +160,19 @@ func DestroyActor(actor interface{}) {
 		}
 		_actorLayers[cur][i] = layer
 	}
+	delete(_actorLayer, actor)
+	delete(_actorCulling, actor)
 	if actor, ok := actor.(Cleanupable); ok {
 		actor.Cleanup()
 	}
 }
+
+// snapshotActors() records the transform of every actor in the
+// current state so that they can be interpolated while rendering.
+func snapshotActors() {
+	for _, actor := range _state.Actors() {
+		if actor, ok := actor.(privatelySnapshottable); ok {
+			actor.snapshot()
+		}
+	}
+}
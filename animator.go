@@ -0,0 +1,467 @@
+package allegory
+
+import (
+	"fmt"
+	"github.com/dradtke/allegory/cache"
+	"github.com/dradtke/allegory/config"
+	"github.com/dradtke/go-allegro/allegro"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// AnimatorSectionPrefix is prepended to an animator's name to get
+// the config section that LoadAnimator() reads it from.
+const AnimatorSectionPrefix = "animator "
+
+type AnimatorStateNotFound struct {
+	Name string
+}
+
+func (e *AnimatorStateNotFound) Error() string {
+	return fmt.Sprintf("animator state %s not found", e.Name)
+}
+
+// AnimatorState is a state that an Animator can be in,
+// each of which plays an animation clip.
+type AnimatorState struct {
+	// Clip is the name of the clip in the cache to play.
+	Clip string
+
+	// Speed scales how quickly the clip plays. A value
+	// of 0 is treated as 1.
+	Speed float32
+
+	// SpeedParam, if set, is the name of a parameter whose value
+	// further scales the speed, e.g. the actor's velocity. The
+	// clip is paused while the parameter is 0.
+	SpeedParam string
+
+	// Times is the number of times to play the clip; see
+	// AnimationProcess for details.
+	Times int
+}
+
+// Condition is a test against one of an Animator's parameters.
+type Condition struct {
+	Param string
+
+	// Op is one of "==", "!=", "<", "<=", ">" or ">=". An empty Op
+	// tests that the parameter isn't 0, and "!" tests that it is.
+	Op string
+
+	Value float32
+}
+
+// Transition is a rule for moving an Animator from one state to another.
+type Transition struct {
+	// From is the state that the transition applies to.
+	// "" or "*" applies it to any state.
+	From string
+
+	// To is the state to move to.
+	To string
+
+	// Conditions must all hold for the transition to be taken.
+	Conditions []Condition
+
+	// ActorStates, if provided, restricts the transition to when the
+	// animator's actor is in one of the named states. See ActorStateName().
+	ActorStates []string
+
+	// Finished restricts the transition to when the current
+	// clip has finished playing.
+	Finished bool
+
+	// Fade is the number of ticks that the previous clip takes to
+	// fade out over the new one. A value of 0 switches immediately.
+	Fade uint
+}
+
+// Animator is a process that decides which animation clip to play based
+// on a set of rules, so that animation logic can live in data instead of
+// in each actor state. Each tick, the first transition whose conditions
+// hold is taken, and then the current clip is advanced.
+//
+// Parameters should only be set from the main thread.
+type Animator struct {
+	state     string
+	params    map[string]float32
+	anim      *AnimationProcess
+	prev      *AnimationProcess
+	finished  bool
+	fade      uint
+	fadeTimer uint
+
+	// Actor is the actor whose state is checked by transitions
+	// that have ActorStates.
+	Actor interface{}
+
+	// States maps each state's name to its definition.
+	States map[string]*AnimatorState
+
+	// Transitions are checked in order.
+	Transitions []*Transition
+
+	// Initial is the state to start in.
+	Initial string
+}
+
+// NewAnimator() creates an animator for actor that starts in initial.
+func NewAnimator(actor interface{}, initial string) *Animator {
+	return &Animator{Actor: actor, Initial: initial, States: make(map[string]*AnimatorState)}
+}
+
+// AddState() adds a state that plays the named clip.
+func (a *Animator) AddState(name, clip string) *AnimatorState {
+	if a.States == nil {
+		a.States = make(map[string]*AnimatorState)
+	}
+	s := &AnimatorState{Clip: clip}
+	a.States[name] = s
+	return s
+}
+
+// AddTransition() adds a transition between two states.
+func (a *Animator) AddTransition(from, to string, conditions ...Condition) *Transition {
+	t := &Transition{From: from, To: to, Conditions: conditions}
+	a.Transitions = append(a.Transitions, t)
+	return t
+}
+
+// Set() sets the value of a parameter.
+func (a *Animator) Set(param string, value float32) {
+	if a.params == nil {
+		a.params = make(map[string]float32)
+	}
+	a.params[param] = value
+}
+
+// SetBool() sets a parameter to 1 if value is true, otherwise 0.
+func (a *Animator) SetBool(param string, value bool) {
+	if value {
+		a.Set(param, 1)
+	} else {
+		a.Set(param, 0)
+	}
+}
+
+// Param() returns the value of a parameter, or 0 if it isn't set.
+func (a *Animator) Param(param string) float32 {
+	return a.params[param]
+}
+
+// State() returns the name of the current state.
+func (a *Animator) State() string {
+	return a.state
+}
+
+// Play() moves straight to a state without checking any transitions.
+func (a *Animator) Play(state string, fade uint) error {
+	def, ok := a.States[state]
+	if !ok {
+		return &AnimatorStateNotFound{state}
+	}
+	clip, err := cache.FindClip(def.Clip)
+	if err != nil {
+		return err
+	}
+	anim := &AnimationProcess{Clip: clip, Speed: def.Speed, Times: def.Times}
+	if err := anim.init(); err != nil {
+		return err
+	}
+	a.prev, a.fade, a.fadeTimer = nil, 0, 0
+	if fade > 0 && a.anim != nil {
+		a.prev, a.fade = a.anim, fade
+	}
+	a.state, a.anim, a.finished = state, anim, false
+	return nil
+}
+
+// CurrentFrame() returns the current frame of the current clip.
+func (a *Animator) CurrentFrame() *allegro.Bitmap {
+	if a.anim == nil {
+		return nil
+	}
+	return a.anim.CurrentFrame()
+}
+
+// Fading() returns the frame of the clip that's fading out and how much
+// of it should still be visible, from 1 down to 0. If nothing's fading
+// out, the frame is nil.
+func (a *Animator) Fading() (*allegro.Bitmap, float32) {
+	if a.prev == nil {
+		return nil, 0
+	}
+	return a.prev.CurrentFrame(), 1 - float32(a.fadeTimer)/float32(a.fade)
+}
+
+func (a *Animator) init() error {
+	return a.Play(a.Initial, 0)
+}
+
+func (a *Animator) tick() (bool, error) {
+	for _, t := range a.Transitions {
+		if t.To != a.state && a.allows(t) {
+			if err := a.Play(t.To, t.Fade); err != nil {
+				return false, err
+			}
+			break
+		}
+	}
+
+	a.speed()
+	if !a.finished {
+		alive, _ := a.anim.tick()
+		a.finished = !alive
+	}
+	if a.prev != nil {
+		a.prev.tick()
+		if a.fadeTimer++; a.fadeTimer >= a.fade {
+			a.prev = nil
+		}
+	}
+	return true, nil
+}
+
+func (a *Animator) handleMessage(msg interface{}) error {
+	if a.anim == nil {
+		return nil
+	}
+	return a.anim.handleMessage(msg)
+}
+
+// allows() returns true if the transition can be taken.
+func (a *Animator) allows(t *Transition) bool {
+	if t.From != "" && t.From != "*" && t.From != a.state {
+		return false
+	}
+	if t.Finished && !a.finished {
+		return false
+	}
+	if len(t.ActorStates) > 0 {
+		name, found := ActorStateName(ActorState(a.Actor)), false
+		for _, s := range t.ActorStates {
+			found = found || s == name
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, c := range t.Conditions {
+		if !c.holds(a.params[c.Param]) {
+			return false
+		}
+	}
+	return true
+}
+
+// holds() returns true if value satisfies the condition.
+func (c Condition) holds(value float32) bool {
+	switch c.Op {
+	case "":
+		return value != 0
+	case "!":
+		return value == 0
+	case "==":
+		return value == c.Value
+	case "!=":
+		return value != c.Value
+	case "<":
+		return value < c.Value
+	case "<=":
+		return value <= c.Value
+	case ">":
+		return value > c.Value
+	case ">=":
+		return value >= c.Value
+	}
+	return false
+}
+
+// speed() applies the current state's speed parameter to its clip.
+func (a *Animator) speed() {
+	def := a.States[a.state]
+	if def == nil || a.anim == nil || def.SpeedParam == "" {
+		return
+	}
+	speed := def.Speed
+	if speed == 0 {
+		speed = 1
+	}
+	value := a.params[def.SpeedParam]
+	if value < 0 {
+		value = -value
+	}
+	a.anim.Speed = speed * value
+	a.anim.Paused = value == 0
+}
+
+// ActorStateName() returns the name that transitions use to refer to an
+// actor state. If the state implements Animated, its AnimationState()
+// is used, otherwise it's the name of its type, e.g. "heroWalking".
+func ActorStateName(state interface{}) string {
+	if state == nil {
+		return ""
+	}
+	if state, ok := state.(Animated); ok {
+		return state.AnimationState()
+	}
+	t := reflect.TypeOf(state)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// LoadAnimator() reads the named animator from the config. It's read
+// from the section named by its name prefixed with AnimatorSectionPrefix,
+// and its clips are looked up in the cache when they're played. For
+// example:
+//
+//	[animator hero]
+//	initial = standing
+//	states = standing, walking, waving
+//	standing = standing                # the clip to play
+//	walking = walking
+//	walking_speed = speed * 1.5        # a number, a parameter, or both
+//	waving_times = 2                   # how many times to play the clip
+//	transitions = walk, stop
+//	walk = standing -> walking, if speed > 0 and grounded
+//	stop = * -> standing, state heroStanding|heroJumping, fade 4
+//
+// After "from -> to", a transition can have any of "if" followed by
+// conditions joined with "and", "state" followed by actor state names
+// joined with "|", "finished", and "fade" followed by a number of ticks.
+// A condition is either a parameter name, a parameter name prefixed with
+// "!", or a comparison between a parameter and a number.
+func LoadAnimator(cfg *allegro.Config, name string) (*Animator, error) {
+	section := AnimatorSectionPrefix + name
+	value := func(key string) string {
+		val, err := cfg.Value(section, key)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(val)
+	}
+	fail := func(format string, args ...interface{}) (*Animator, error) {
+		return nil, fmt.Errorf("animator %s: %s", name, fmt.Sprintf(format, args...))
+	}
+
+	a := NewAnimator(nil, value("initial"))
+	for _, state := range config.SplitList(value("states")) {
+		clip := value(state)
+		if clip == "" {
+			clip = state
+		}
+		def := a.AddState(state, clip)
+		if speed := value(state + "_speed"); speed != "" {
+			for _, factor := range strings.Split(speed, "*") {
+				factor = strings.TrimSpace(factor)
+				if f, err := strconv.ParseFloat(factor, 32); err == nil {
+					def.Speed = float32(f)
+				} else {
+					def.SpeedParam = factor
+				}
+			}
+		}
+		if times := value(state + "_times"); times != "" {
+			n, err := strconv.Atoi(times)
+			if err != nil {
+				return fail("state %s: invalid times %q", state, times)
+			}
+			def.Times = n
+		}
+	}
+	if _, ok := a.States[a.Initial]; !ok {
+		return fail("invalid initial state %q", a.Initial)
+	}
+
+	for _, key := range config.SplitList(value("transitions")) {
+		t, err := parseTransition(value(key))
+		if err != nil {
+			return fail("transition %s: %s", key, err)
+		}
+		if _, ok := a.States[t.To]; !ok {
+			return fail("transition %s: unknown state %q", key, t.To)
+		}
+		a.Transitions = append(a.Transitions, t)
+	}
+	return a, nil
+}
+
+// parseTransition() parses a transition in the format read by LoadAnimator().
+func parseTransition(s string) (*Transition, error) {
+	parts := config.SplitList(s)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("empty transition")
+	}
+	states := strings.SplitN(parts[0], "->", 2)
+	if len(states) != 2 {
+		return nil, fmt.Errorf("expected \"from -> to\", got %q", parts[0])
+	}
+	t := &Transition{From: strings.TrimSpace(states[0]), To: strings.TrimSpace(states[1])}
+
+	for _, part := range parts[1:] {
+		fields := strings.Fields(part)
+		switch fields[0] {
+		case "if":
+			for _, cond := range strings.Split(strings.TrimSpace(part[2:]), " and ") {
+				c, err := parseCondition(cond)
+				if err != nil {
+					return nil, err
+				}
+				t.Conditions = append(t.Conditions, c)
+			}
+		case "state":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("invalid actor state %q", part)
+			}
+			t.ActorStates = strings.Split(fields[1], "|")
+		case "finished":
+			t.Finished = true
+		case "fade":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("invalid fade %q", part)
+			}
+			fade, err := strconv.ParseUint(fields[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid fade %q", part)
+			}
+			t.Fade = uint(fade)
+		default:
+			return nil, fmt.Errorf("unknown option %q", part)
+		}
+	}
+	return t, nil
+}
+
+// parseCondition() parses a single condition, e.g. "speed > 0".
+func parseCondition(s string) (Condition, error) {
+	fields := strings.Fields(s)
+	switch len(fields) {
+	case 1:
+		if strings.HasPrefix(fields[0], "!") {
+			return Condition{Param: fields[0][1:], Op: "!"}, nil
+		}
+		return Condition{Param: fields[0]}, nil
+	case 3:
+		c := Condition{Param: fields[0], Op: fields[1]}
+		value, err := strconv.ParseFloat(fields[2], 32)
+		if err != nil || !c.validOp() {
+			break
+		}
+		c.Value = float32(value)
+		return c, nil
+	}
+	return Condition{}, fmt.Errorf("invalid condition %q", s)
+}
+
+// validOp() returns true if the condition's operator is a comparison.
+func (c Condition) validOp() bool {
+	switch c.Op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package allegory
+
+import (
+	"github.com/dradtke/allegory/cache"
+	"github.com/dradtke/allegory/config"
+)
+
+// CheckAssets() checks the asset manifest set with config.SetAssetManifest()
+// against the files in the cache's filesystem. Files are only read, not
+// decoded, so it's quick enough to run on every startup; use the assets
+// command for a thorough check.
+func CheckAssets() (*cache.ManifestReport, error) {
+	m, err := cache.LoadManifest(config.AssetManifest())
+	if err != nil {
+		return nil, err
+	}
+	return m.Check(false), nil
+}
+
+// checkAssets() runs CheckAssets() on startup if there's a manifest. In
+// strict mode, missing or unreadable assets stop the game with the full
+// report; otherwise they're logged, and unreferenced files always are.
+func checkAssets() {
+	if config.AssetManifest() == "" {
+		return
+	}
+	report, err := CheckAssets()
+	if err != nil {
+		if config.StrictAssets() {
+			Fatal(err)
+		}
+		Error(err)
+		return
+	}
+	if !report.OK() && config.StrictAssets() {
+		Fatal(report)
+	}
+	for _, p := range report.Missing {
+		Errorf("missing asset: %s", p)
+	}
+	for _, err := range report.Unreadable {
+		Error(err)
+	}
+	for _, p := range report.Unreferenced {
+		Debugf("unreferenced asset: %s", p)
+	}
+}
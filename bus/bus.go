@@ -28,7 +28,10 @@ type EventId uint32
 var (
 	_bus            = make(map[EventId]*list.List)
 	_curried        = make(map[*list.Element][]reflect.Value)
+	_once           = make(map[*list.Element]bool)
 	_eventIdCounter EventId
+
+	_anyParams = reflect.TypeOf([]interface{}(nil))
 )
 
 // NewEventId() uses an internal counter to return a new valid event
@@ -39,8 +42,8 @@ func NewEventId() EventId {
 }
 
 // Signal() calls all of the registered listeners for a given
-// event type, as long as the parameters exactly match the ones
-// that were passed into this function. For example, this works:
+// event type, as long as the values that were passed into this
+// function can be assigned to their parameters. For example, this works:
 //
 //      const MyEventId EventId = 1
 //
@@ -58,7 +61,8 @@ func NewEventId() EventId {
 // would be issued out to standard error.
 //
 // As long as the parameters line up, listeners can take any number
-// of parameters, including 0.
+// of parameters, including 0. A listener with the signature
+// func(params ...interface{}) accepts any parameters at all.
 //
 func Signal(eventType EventId, params ...interface{}) {
 	listeners, ok := _bus[eventType]
@@ -70,13 +74,28 @@ func Signal(eventType EventId, params ...interface{}) {
 		paramValues[i] = reflect.ValueOf(param)
 	}
 	numParams := len(paramValues)
+	var next *list.Element
 loop:
-	for e := listeners.Front(); e != nil; e = e.Next() {
+	for e := listeners.Front(); e != nil; e = next {
+		next = e.Next()
 		curriedValues := _curried[e]
 		numCurried := len(curriedValues)
 		n := numParams + numCurried
 		f := reflect.ValueOf(e.Value)
 		t := f.Type()
+		if _once[e] {
+			listeners.Remove(e)
+			delete(_curried, e)
+			delete(_once, e)
+		}
+		if t.NumIn() == 1 && t.IsVariadic() && t.In(0) == _anyParams {
+			anyValues := make([]interface{}, 0, n)
+			for _, v := range curriedValues {
+				anyValues = append(anyValues, v.Interface())
+			}
+			f.CallSlice([]reflect.Value{reflect.ValueOf(append(anyValues, params...))})
+			continue loop
+		}
 		if t.NumIn() != n {
 			fmt.Fprintf(os.Stderr, "invalid callback registerd for event type %d: "+
 				"need %d parameters, but have %d\n", eventType, n, t.NumIn())
@@ -87,14 +106,16 @@ loop:
 			in := t.In(i)
 			failed := false
 			if i < numCurried {
-				if in != paramValues[i].Type() {
+				if !assignable(curriedValues[i], in) {
 					failed = true
 				} else {
 					allValues[i] = curriedValues[i]
 				}
 			} else {
-				if in != paramValues[i-numCurried].Type() {
+				if !assignable(paramValues[i-numCurried], in) {
 					failed = true
+				} else if !paramValues[i-numCurried].IsValid() {
+					allValues[i] = reflect.Zero(in)
 				} else {
 					allValues[i] = paramValues[i-numCurried]
 				}
@@ -111,6 +132,19 @@ loop:
 	}
 }
 
+// assignable() returns true if v can be passed as a parameter of type t.
+// Nil values can be passed to any parameter that accepts nil.
+func assignable(v reflect.Value, t reflect.Type) bool {
+	if !v.IsValid() {
+		switch t.Kind() {
+		case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+			return true
+		}
+		return false
+	}
+	return v.Type().AssignableTo(t)
+}
+
 // AddListener() registers a handler for a given event type.
 func AddListener(eventType EventId, f interface{}, curry ...interface{}) error {
 	if reflect.ValueOf(f).Kind() != reflect.Func {
@@ -130,6 +164,51 @@ func AddListener(eventType EventId, f interface{}, curry ...interface{}) error {
 	return nil
 }
 
+// Once() registers a handler for a given event type that is
+// unregistered the first time the event is signaled.
+func Once(eventType EventId, f interface{}, curry ...interface{}) error {
+	if err := AddListener(eventType, f, curry...); err != nil {
+		return err
+	}
+	_once[_bus[eventType].Back()] = true
+	return nil
+}
+
+// Listener is a handle to a registered handler,
+// which can be used to unregister it.
+type Listener struct {
+	eventType EventId
+	e         *list.Element
+}
+
+// Listen() is like AddListener(), but returns a handle to the handler.
+func Listen(eventType EventId, f interface{}, curry ...interface{}) (*Listener, error) {
+	if err := AddListener(eventType, f, curry...); err != nil {
+		return nil, err
+	}
+	return &Listener{eventType, _bus[eventType].Back()}, nil
+}
+
+// ListenOnce() is like Once(), but returns a handle to the handler.
+func ListenOnce(eventType EventId, f interface{}, curry ...interface{}) (*Listener, error) {
+	l, err := Listen(eventType, f, curry...)
+	if err != nil {
+		return nil, err
+	}
+	_once[l.e] = true
+	return l, nil
+}
+
+// Remove() unregisters the handler. It has no effect if the
+// handler has already been unregistered.
+func (l *Listener) Remove() {
+	if listeners, ok := _bus[l.eventType]; ok {
+		listeners.Remove(l.e)
+	}
+	delete(_curried, l.e)
+	delete(_once, l.e)
+}
+
 // RemoveListener() unregisters a handler for a given event type.
 func RemoveListener(eventType EventId, f interface{}) error {
 	listeners := _bus[eventType]
@@ -152,6 +231,7 @@ func Clear(eventType EventId) {
 	}
 	for e := listeners.Front(); e != nil; e = e.Next() {
 		delete(_bus, eventType)
+		delete(_once, e)
 	}
 	listeners.Init()
 	runtime.GC()
@@ -163,6 +243,7 @@ func ClearAll() {
 	for eventType, listeners := range _bus {
 		for e := listeners.Front(); e != nil; e = e.Next() {
 			delete(_curried, e)
+			delete(_once, e)
 		}
 		listeners.Init()
 		delete(_bus, eventType)
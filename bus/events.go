@@ -1,8 +1,28 @@
 package bus
 
 const (
-	_ = EventId(^uint(0) - iota)
+	_ = EventId(^uint32(0) - iota)
 
 	// Handler signature: func(cmd string)
 	ConsoleCommandEvent
+
+	// Handler signature: func(proc interface{}, err error)
+	//
+	// Signaled whenever a process fails, including when it panics.
+	// proc is the process value, not its handle.
+	ProcessFailedEvent
+
+	// Handler signature: func(anim interface{}, event string)
+	//
+	// Signaled whenever an animation shows a frame that has an
+	// event, such as "footstep". anim is the *AnimationProcess,
+	// and handlers are called from whichever goroutine ticks it.
+	AnimationFrameEvent
+
+	// Handler signature: func(kind cache.ResourceKind, key string)
+	//
+	// Signaled on the main thread whenever a resource in the cache
+	// is hot reloaded, after any configs bound with BindConfig()
+	// have been read again.
+	ResourceReloadedEvent
 )
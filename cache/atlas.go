@@ -0,0 +1,244 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dradtke/go-allegro/allegro"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+)
+
+// AtlasKeyPrefix is prepended to the index of each atlas
+// page to get the key that it's cached under.
+const AtlasKeyPrefix = "atlas#"
+
+// _atlasCount is the number of atlas pages that have been
+// created, so that each one gets a unique key.
+var _atlasCount int
+
+// AtlasOptions controls how images are packed into atlases.
+type AtlasOptions struct {
+	// Width and Height are the size of each atlas page. Images that
+	// don't fit on a page by themselves are left unpacked. They
+	// default to 1024.
+	Width, Height int
+
+	// Padding is the number of transparent pixels left around each
+	// image, to stop their edges from bleeding into each other when
+	// drawn scaled or rotated.
+	Padding int
+}
+
+// Region is the area of an atlas taken up by a single image.
+type Region struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// Atlas is a single page of packed images.
+type Atlas struct {
+	// Key is the key that the page's bitmap is cached under.
+	Key string
+
+	Width, Height int
+
+	// Regions maps the key of each packed image to its area of the page.
+	Regions map[string]Region
+}
+
+// PackImages() packs cached images into as few atlas pages as it can, so
+// that drawing them can be batched by Allegro's held bitmap drawing. Each
+// page is added to the cache under AtlasKeyPrefix followed by its index,
+// and each image is replaced by a sub-bitmap of its page under the same
+// key, so callers of Image() and FindImage() don't notice a difference.
+//
+// If keys is empty, every image loaded from a file is packed, except for
+// sub-bitmaps and images that have sub-bitmaps of their own, such as
+// sprite sheets, which can't be packed at all. The original bitmaps are
+// destroyed, so this should be done before anything holds on to them
+// directly, such as an animation clip.
+func PackImages(opts AtlasOptions, keys ...string) ([]*Atlas, error) {
+	if opts.Width <= 0 {
+		opts.Width = 1024
+	}
+	if opts.Height <= 0 {
+		opts.Height = 1024
+	}
+
+	_resourcesMutex.Lock()
+	defer _resourcesMutex.Unlock()
+
+	hasChildren := make(map[*resource]bool)
+	for _, r := range _resources[ImageResource] {
+		if r.parent != nil {
+			hasChildren[r.parent] = true
+		}
+	}
+	if len(keys) == 0 {
+		for key, r := range _resources[ImageResource] {
+			if r.parent == nil && !hasChildren[r] && r.Path != "" {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+	}
+	items := make([]packItem, 0, len(keys))
+	for _, key := range keys {
+		r, ok := _resources[ImageResource][key]
+		if !ok {
+			return nil, &ImageNotFound{key}
+		}
+		if hasChildren[r] {
+			return nil, fmt.Errorf("image %s has sub-bitmaps, so it can't be packed", key)
+		}
+		bmp := r.value.(*allegro.Bitmap)
+		items = append(items, packItem{key: key, w: bmp.Width(), h: bmp.Height()})
+	}
+
+	pages := packShelves(items, opts.Width, opts.Height, opts.Padding)
+	atlases := make([]*Atlas, len(pages))
+	for i, page := range pages {
+		atlas := &Atlas{
+			Key:     AtlasKeyPrefix + fmt.Sprint(_atlasCount),
+			Width:   opts.Width,
+			Height:  opts.Height,
+			Regions: make(map[string]Region, len(page)),
+		}
+		sheet := allegro.CreateBitmap(atlas.Width, atlas.Height)
+		if sheet == nil {
+			return atlases[:i], fmt.Errorf("failed to create %dx%d atlas", atlas.Width, atlas.Height)
+		}
+		sheet.AsTarget(func() {
+			allegro.ClearToColor(allegro.MapRGBA(0, 0, 0, 0))
+			for _, item := range page {
+				_resources[ImageResource][item.key].value.(*allegro.Bitmap).Draw(float32(item.x), float32(item.y), allegro.FLIP_NONE)
+			}
+		})
+		subs := make([]*allegro.Bitmap, len(page))
+		for j, item := range page {
+			if subs[j] = sheet.CreateSubBitmap(item.x, item.y, item.w, item.h); subs[j] == nil {
+				for _, sub := range subs[:j] {
+					sub.Destroy()
+				}
+				sheet.Destroy()
+				return atlases[:i], fmt.Errorf("failed to create a sub-bitmap of %s for image %s", atlas.Key, item.key)
+			}
+		}
+		_atlasCount++
+		parent := add(ImageResource, atlas.Key, "", sheet, nil)
+		for j, item := range page {
+			atlas.Regions[item.key] = Region{item.x, item.y, item.w, item.h}
+			r := _resources[ImageResource][item.key]
+			r.value.(*allegro.Bitmap).Destroy()
+			r.value, r.area = subs[j], atlas.Regions[item.key]
+			old := r.parent
+			r.parent = parent
+			parent.Refs++
+			if old != nil {
+				// It was already packed into another page.
+				release(old)
+			}
+		}
+		// The page only lives as long as its images do.
+		parent.Refs--
+		atlases[i] = atlas
+	}
+	return atlases, nil
+}
+
+// Save() writes the atlas's bitmap to imagePath and its manifest to
+// manifestPath, using TexturePacker's JSON hash format so that it
+// can be loaded again with LoadTexturePacker().
+func (a *Atlas) Save(imagePath, manifestPath string) error {
+	sheet, err := FindImage(a.Key)
+	if err != nil {
+		return err
+	}
+	if err := allegro.SaveBitmap(imagePath, sheet); err != nil {
+		return err
+	}
+
+	type frame struct {
+		Frame   Region `json:"frame"`
+		Rotated bool   `json:"rotated"`
+	}
+	manifest := struct {
+		Frames map[string]frame `json:"frames"`
+		Meta   struct {
+			Image string         `json:"image"`
+			Size  map[string]int `json:"size"`
+		} `json:"meta"`
+	}{Frames: make(map[string]frame, len(a.Regions))}
+	for key, region := range a.Regions {
+		manifest.Frames[key] = frame{Frame: region}
+	}
+	manifest.Meta.Image, _ = filepath.Rel(filepath.Dir(manifestPath), imagePath)
+	manifest.Meta.Image = filepath.ToSlash(manifest.Meta.Image)
+	manifest.Meta.Size = map[string]int{"w": a.Width, "h": a.Height}
+
+	data, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(manifestPath, data, 0644)
+}
+
+/* -- Packing -- */
+
+// packItem is an image to be placed on an atlas page.
+type packItem struct {
+	key        string
+	x, y, w, h int
+}
+
+type byHeight []packItem
+
+func (s byHeight) Len() int      { return len(s) }
+func (s byHeight) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byHeight) Less(i, j int) bool {
+	if s[i].h != s[j].h {
+		return s[i].h > s[j].h
+	}
+	return s[i].w > s[j].w
+}
+
+// packShelves() places items onto pages using a shelf packer: the tallest
+// items go first, filling rows from left to right, and a new row starts
+// below the tallest item of the last one when an item doesn't fit. Items
+// that are too big for a page are left out.
+func packShelves(items []packItem, width, height, padding int) [][]packItem {
+	sorted := make([]packItem, len(items))
+	copy(sorted, items)
+	sort.Stable(byHeight(sorted))
+
+	pages := make([][]packItem, 0)
+	var (
+		page              []packItem
+		x, y, shelfHeight int
+	)
+	for _, item := range sorted {
+		if item.w+2*padding > width || item.h+2*padding > height {
+			continue
+		}
+		if x+item.w+2*padding > width {
+			x, y, shelfHeight = 0, y+shelfHeight, 0
+		}
+		if y+item.h+2*padding > height {
+			pages = append(pages, page)
+			page, x, y, shelfHeight = nil, 0, 0, 0
+		}
+		item.x, item.y = x+padding, y+padding
+		page = append(page, item)
+		x += item.w + 2*padding
+		if item.h+2*padding > shelfHeight {
+			shelfHeight = item.h + 2*padding
+		}
+	}
+	if len(page) > 0 {
+		pages = append(pages, page)
+	}
+	return pages
+}
@@ -0,0 +1,370 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/dradtke/allegory/config"
+	"github.com/dradtke/go-allegro/allegro"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PlayMode determines what an animation clip does once
+// it reaches its last frame.
+type PlayMode int
+
+const (
+	// PlayOnce stops on the last frame.
+	PlayOnce PlayMode = iota
+
+	// PlayLoop starts over from the first frame.
+	PlayLoop
+
+	// PlayPingPong plays the frames in reverse back to the first
+	// one, then forwards again, and so on.
+	PlayPingPong
+)
+
+// ClipSectionPrefix is prepended to a clip's name to get the
+// config section that LoadClips() reads it from.
+const ClipSectionPrefix = "clip "
+
+// DefaultFrameDuration is the number of ticks that each frame
+// of a clip loaded from config is shown for if no duration
+// is specified.
+const DefaultFrameDuration = 6
+
+var _clips = make(map[string]*AnimationClip)
+
+// Frame is a single frame of an animation clip.
+type Frame struct {
+	// Image is the bitmap to show.
+	Image *allegro.Bitmap
+
+	// Duration is the number of ticks to show it for.
+	// A value of 0 is treated as 1.
+	Duration uint
+
+	// Event, if not empty, is the name of an event that's
+	// signaled when the frame is shown, e.g. "footstep".
+	Event string
+}
+
+// AnimationClip is a named list of frames to be played in order.
+type AnimationClip struct {
+	Name   string
+	Frames []Frame
+	Mode   PlayMode
+}
+
+// Length() returns the total number of ticks that it takes
+// to play each of the clip's frames once.
+func (c *AnimationClip) Length() (ticks uint) {
+	for _, frame := range c.Frames {
+		if frame.Duration == 0 {
+			ticks++
+		} else {
+			ticks += frame.Duration
+		}
+	}
+	return
+}
+
+type ClipNotFound struct {
+	Name string
+}
+
+func (e *ClipNotFound) Error() string {
+	return fmt.Sprintf("animation clip %s not found", e.Name)
+}
+
+// NewClip() creates a clip from a list of images, showing
+// each one for the same number of ticks. It isn't added
+// to the cache.
+func NewClip(name string, duration uint, mode PlayMode, images ...*allegro.Bitmap) *AnimationClip {
+	clip := &AnimationClip{Name: name, Mode: mode, Frames: make([]Frame, len(images))}
+	for i, img := range images {
+		clip.Frames[i] = Frame{Image: img, Duration: duration}
+	}
+	return clip
+}
+
+// AddClip() adds a clip to the cache under its name,
+// replacing any existing clip with the same name.
+func AddClip(clip *AnimationClip) {
+	_clips[clip.Name] = clip
+}
+
+// ClearClips() removes all clips from the cache. It doesn't
+// destroy any of their images.
+func ClearClips() {
+	for name := range _clips {
+		delete(_clips, name)
+	}
+}
+
+// FindClip() finds a clip in the cache. If it doesn't
+// exist, an error of type ClipNotFound is returned.
+func FindClip(name string) (*AnimationClip, error) {
+	if clip, ok := _clips[name]; ok {
+		return clip, nil
+	}
+	return nil, &ClipNotFound{name}
+}
+
+// Clip() gets a clip from the cache using FindClip(),
+// panicking if it isn't found.
+func Clip(name string) *AnimationClip {
+	clip, err := FindClip(name)
+	if err != nil {
+		panic(err)
+	}
+	return clip
+}
+
+// SheetFrameKey() returns the key that frame i of the
+// sprite sheet stored under key is cached as.
+func SheetFrameKey(key string, i int) string {
+	return key + "#" + strconv.Itoa(i)
+}
+
+// SliceSheet() cuts the cached image stored under key into frames of
+// the given size, reading left to right and top to bottom. Each frame
+// is a sub-bitmap of the sheet, and is added to the cache under the
+// key returned by SheetFrameKey(). Slicing a sheet again the same way
+// reuses the frames that are already cached, adding a reference to each.
+func SliceSheet(key string, frameWidth, frameHeight int) ([]*allegro.Bitmap, error) {
+	sheet, err := FindImage(key)
+	if err != nil {
+		return nil, err
+	}
+	if frameWidth <= 0 || frameHeight <= 0 {
+		return nil, fmt.Errorf("invalid frame size %dx%d for sheet %s", frameWidth, frameHeight, key)
+	}
+	cols, rows := sheet.Width()/frameWidth, sheet.Height()/frameHeight
+	frames := make([]*allegro.Bitmap, 0, cols*rows)
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			area := Region{x * frameWidth, y * frameHeight, frameWidth, frameHeight}
+			frame, err := subImage(key, SheetFrameKey(key, len(frames)), area)
+			if err != nil {
+				return nil, fmt.Errorf("failed to slice frame %d of sheet %s: %s", len(frames), key, err)
+			}
+			frames = append(frames, frame)
+		}
+	}
+	return frames, nil
+}
+
+// LoadClips() reads each of the named clips from the config and adds
+// them to the cache. A clip is read from the section named by its name
+// prefixed with ClipSectionPrefix, and the images it refers to should
+// already be loaded. For example:
+//
+//	[clip walking]
+//	sheet = hero.png       # the sprite sheet to slice frames from
+//	frame_size = 32x48     # the size of each frame in the sheet
+//	frames = 0-7           # which frames of the sheet to use, in order
+//	duration = 6           # how many ticks to show each frame for
+//	durations = 6,4,6,4    # or, a duration for each frame
+//	mode = loop            # once, loop or pingpong
+//	events = 2:footstep, 6:footstep
+//
+// Instead of a sheet, "images" can list image keys, any of which can
+// be a pattern such as walking-*.png. Each pattern's matches are
+// sorted so that numbered images are in order.
+func LoadClips(cfg *allegro.Config, names ...string) error {
+	for _, name := range names {
+		clip, err := readClip(cfg, name)
+		if err != nil {
+			return err
+		}
+		AddClip(clip)
+	}
+	return nil
+}
+
+// readClip() reads the named clip from the config.
+func readClip(cfg *allegro.Config, name string) (*AnimationClip, error) {
+	section := ClipSectionPrefix + name
+	value := func(key string) string {
+		val, err := cfg.Value(section, key)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(val)
+	}
+	fail := func(format string, args ...interface{}) (*AnimationClip, error) {
+		return nil, fmt.Errorf("clip %s: %s", name, fmt.Sprintf(format, args...))
+	}
+
+	var images []*allegro.Bitmap
+	if sheet := value("sheet"); sheet != "" {
+		var w, h int
+		if _, err := fmt.Sscanf(value("frame_size"), "%dx%d", &w, &h); err != nil {
+			return fail("invalid frame_size %q", value("frame_size"))
+		}
+		frames, err := SliceSheet(sheet, w, h)
+		if err != nil {
+			return nil, err
+		}
+		if indices := value("frames"); indices == "" {
+			images = frames
+		} else {
+			list, err := parseRanges(indices)
+			if err != nil {
+				return fail("%s", err)
+			}
+			for _, i := range list {
+				if i < 0 || i >= len(frames) {
+					return fail("sheet %s has no frame %d", sheet, i)
+				}
+				images = append(images, frames[i])
+			}
+		}
+	} else if keys := value("images"); keys != "" {
+		for _, key := range matchImages(config.SplitList(keys)) {
+			img, err := FindImage(key)
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, img)
+		}
+	}
+	if len(images) == 0 {
+		return fail("no frames")
+	}
+
+	var duration uint = DefaultFrameDuration
+	if val := value("duration"); val != "" {
+		d, err := strconv.ParseUint(val, 10, 32)
+		if err != nil {
+			return fail("invalid duration %q", val)
+		}
+		duration = uint(d)
+	}
+	mode, err := ParsePlayMode(value("mode"))
+	if err != nil {
+		return fail("%s", err)
+	}
+	clip := NewClip(name, duration, mode, images...)
+
+	if val := value("durations"); val != "" {
+		for i, s := range config.SplitList(val) {
+			d, err := strconv.ParseUint(s, 10, 32)
+			if err != nil || i >= len(clip.Frames) {
+				return fail("invalid durations %q", val)
+			}
+			clip.Frames[i].Duration = uint(d)
+		}
+	}
+	if val := value("events"); val != "" {
+		for _, s := range config.SplitList(val) {
+			parts := strings.SplitN(s, ":", 2)
+			i, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+			if err != nil || len(parts) != 2 || i < 0 || i >= len(clip.Frames) {
+				return fail("invalid event %q", s)
+			}
+			clip.Frames[i].Event = strings.TrimSpace(parts[1])
+		}
+	}
+	return clip, nil
+}
+
+// ParsePlayMode() converts "once", "loop" or "pingpong" into a PlayMode.
+// An empty string is treated as "once".
+func ParsePlayMode(s string) (PlayMode, error) {
+	switch strings.ToLower(strings.Replace(s, "-", "", -1)) {
+	case "", "once":
+		return PlayOnce, nil
+	case "loop":
+		return PlayLoop, nil
+	case "pingpong":
+		return PlayPingPong, nil
+	}
+	return PlayOnce, fmt.Errorf("unknown play mode %q", s)
+}
+
+// parseRanges() parses a list of indices such as "0-3, 5, 7-6".
+func parseRanges(s string) ([]int, error) {
+	indices := make([]int, 0)
+	for _, item := range config.SplitList(s) {
+		bounds := strings.SplitN(item, "-", 2)
+		from, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid frame range %q", item)
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil {
+				return nil, fmt.Errorf("invalid frame range %q", item)
+			}
+		}
+		step := 1
+		if to < from {
+			step = -1
+		}
+		for i := from; i != to+step; i += step {
+			indices = append(indices, i)
+		}
+	}
+	return indices, nil
+}
+
+// matchImages() expands any patterns in keys into the
+// keys of the cached images that they match.
+func matchImages(keys []string) []string {
+	matched := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !strings.ContainsAny(key, "*?[") {
+			matched = append(matched, key)
+			continue
+		}
+		matches := make([]string, 0)
+		for _, k := range resourceKeys(ImageResource) {
+			if ok, _ := path.Match(key, k); ok {
+				matches = append(matches, k)
+			}
+		}
+		sort.Sort(byNaturalOrder(matches))
+		matched = append(matched, matches...)
+	}
+	return matched
+}
+
+// byNaturalOrder sorts strings so that runs of digits are
+// compared by their value, e.g. "walking-2" < "walking-10".
+type byNaturalOrder []string
+
+func (s byNaturalOrder) Len() int      { return len(s) }
+func (s byNaturalOrder) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byNaturalOrder) Less(i, j int) bool {
+	a, b := s[i], s[j]
+	for a != "" && b != "" {
+		da, db := digitPrefix(a), digitPrefix(b)
+		if da != "" && db != "" {
+			na, _ := strconv.Atoi(da)
+			nb, _ := strconv.Atoi(db)
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+// digitPrefix() returns the run of digits at the start of s.
+func digitPrefix(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/dradtke/go-allegro/allegro"
+)
+
+type ConfigNotFound struct {
+	Key string
+}
+
+func (e *ConfigNotFound) Error() string {
+	return fmt.Sprintf("config %s not found", e.Key)
+}
+
+// LoadConfig() loads a config file into the cache. If a config is already
+// cached under key, it gains a reference instead of being loaded again.
+func LoadConfig(path, key string) error {
+	if key == "" {
+		key = path
+	}
+	return load(ConfigResource, key, path, func() (interface{}, error) {
+		return openResource(ConfigResource, path, 0)
+	})
+}
+
+// ReleaseConfig() releases a reference to a config, destroying it
+// if it was the last one.
+func ReleaseConfig(key string) error {
+	return Release(ConfigResource, key)
+}
+
+// FindConfig() finds a config in the cache. If it
+// doesn't exist, an error of type ConfigNotFound is returned.
+func FindConfig(key string) (*allegro.Config, error) {
+	if cfg, ok := find(ConfigResource, key); ok {
+		return cfg.(*allegro.Config), nil
+	}
+	return nil, &ConfigNotFound{key}
+}
+
+// Config() gets a config from the cache using FindConfig(),
+// panicking if it isn't found.
+func Config(key string) *allegro.Config {
+	cfg, err := FindConfig(key)
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
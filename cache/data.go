@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"fmt"
+)
+
+type DataNotFound struct {
+	Key string
+}
+
+func (e *DataNotFound) Error() string {
+	return fmt.Sprintf("data %s not found", e.Key)
+}
+
+// LoadData() reads the contents of a file into the cache. If data is already
+// cached under key, it gains a reference instead of being read again.
+func LoadData(path, key string) error {
+	if key == "" {
+		key = path
+	}
+	return load(DataResource, key, path, func() (interface{}, error) {
+		return openResource(DataResource, path, 0)
+	})
+}
+
+// AddData() adds data to the cache under key, replacing anything
+// that's already stored there.
+func AddData(key string, data []byte) {
+	_resourcesMutex.Lock()
+	defer _resourcesMutex.Unlock()
+	add(DataResource, key, "", data, nil)
+}
+
+// ReleaseData() releases a reference to some data, removing it
+// from the cache if it was the last one.
+func ReleaseData(key string) error {
+	return Release(DataResource, key)
+}
+
+// FindData() finds some data in the cache. If it
+// doesn't exist, an error of type DataNotFound is returned.
+func FindData(key string) ([]byte, error) {
+	if data, ok := find(DataResource, key); ok {
+		return data.([]byte), nil
+	}
+	return nil, &DataNotFound{key}
+}
+
+// Data() gets some data from the cache using FindData(),
+// panicking if it isn't found.
+func Data(key string) []byte {
+	data, err := FindData(key)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
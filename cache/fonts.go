@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/dradtke/go-allegro/allegro/font"
+)
+
+type FontNotFound struct {
+	Key  string
+	Size int
+}
+
+func (e *FontNotFound) Error() string {
+	return fmt.Sprintf("font %s at size %d not found", e.Key, e.Size)
+}
+
+// FontKey() returns the key that a font loaded under key at
+// the given size is cached as, for use with Retain() and Release().
+func FontKey(key string, size int) string {
+	return fmt.Sprintf("%s@%d", key, size)
+}
+
+// LoadFont() loads a font at the given size into the cache. The same
+// font can be loaded under the same key at any number of sizes. If it's
+// already cached at this size, it gains a reference instead of being
+// loaded again. When reading from a filesystem set with SetFS(), only
+// TrueType fonts are supported.
+func LoadFont(path string, size int, key string) error {
+	if key == "" {
+		key = path
+	}
+	return load(FontResource, FontKey(key, size), path, func() (interface{}, error) {
+		return openResource(FontResource, path, size)
+	})
+}
+
+// ReleaseFont() releases a reference to a font at the given
+// size, destroying it if it was the last one.
+func ReleaseFont(key string, size int) error {
+	if err := Release(FontResource, FontKey(key, size)); err != nil {
+		return &FontNotFound{key, size}
+	}
+	return nil
+}
+
+// FindFont() finds a font at the given size in the cache. If it
+// doesn't exist, an error of type FontNotFound is returned.
+func FindFont(key string, size int) (*font.Font, error) {
+	if f, ok := find(FontResource, FontKey(key, size)); ok {
+		return f.(*font.Font), nil
+	}
+	return nil, &FontNotFound{key, size}
+}
+
+// Font() gets a font from the cache using FindFont(),
+// panicking if it isn't found.
+func Font(key string, size int) *font.Font {
+	f, err := FindFont(key, size)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"github.com/dradtke/go-allegro/allegro"
+	"github.com/dradtke/go-allegro/allegro/audio"
+	"github.com/dradtke/go-allegro/allegro/font"
+	"github.com/dradtke/go-allegro/allegro/memfile"
+	"github.com/dradtke/go-allegro/allegro/ttf"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// _fs is the filesystem that resources are read from,
+// or nil to read them from the operating system's.
+var _fs fs.FS
+
+// SetFS() makes the cache read every file from fsys instead of the
+// operating system's filesystem, so that assets can be embedded into
+// the binary with embed.FS or shipped in a data pack. Paths are then
+// slash-separated and relative to the root of fsys. For example, to
+// read everything from a zip archive:
+//
+//	pack, err := zip.OpenReader("data.zip")
+//	if err != nil {
+//		allegory.Fatal(err)
+//	}
+//	cache.SetFS(pack)
+//
+// Passing nil goes back to the operating system's filesystem.
+// Resources that are already loaded aren't affected.
+func SetFS(fsys fs.FS) {
+	_fs = fsys
+}
+
+// FS() returns the filesystem set with SetFS(), or nil
+// if files are read from the operating system's.
+func FS() fs.FS {
+	return _fs
+}
+
+// ReadFile() reads the whole of a file from the cache's filesystem.
+func ReadFile(name string) ([]byte, error) {
+	if _fs == nil {
+		return os.ReadFile(name)
+	}
+	return fs.ReadFile(_fs, name)
+}
+
+// OpenImage() loads an image from the cache's filesystem without
+// adding it to the cache.
+func OpenImage(name string) (*allegro.Bitmap, error) {
+	f, err := openMemfile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return allegro.LoadBitmapF(f, path.Ext(name))
+}
+
+// openResource() loads the file at name as the given kind of resource.
+// Files are read into memory and decoded from there with Allegro's file
+// interface, so that they can come from any filesystem. size is only
+// used by fonts.
+func openResource(kind ResourceKind, name string, size int) (interface{}, error) {
+	switch kind {
+	case ImageResource:
+		return OpenImage(name)
+	case FontResource:
+		if _fs == nil {
+			return font.LoadFont(name, size, 0)
+		}
+		// TTF fonts keep reading from the file as glyphs are
+		// needed, so it's left open for as long as the font is.
+		f, err := openMemfile(name)
+		if err != nil {
+			return nil, err
+		}
+		return ttf.LoadFontF(f, name, size, 0)
+	case SampleResource:
+		f, err := openMemfile(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return audio.LoadSampleF(f, path.Ext(name))
+	case ConfigResource:
+		f, err := openMemfile(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return allegro.LoadConfigF(f)
+	case DataResource:
+		return ReadFile(name)
+	}
+	return nil, &ResourceNotFound{kind, name}
+}
+
+// openMemfile() reads a file into memory and opens it with Allegro.
+func openMemfile(name string) (*allegro.File, error) {
+	data, err := ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return memfile.Open(data, "r")
+}
+
+// statFile() returns information about a file in the cache's filesystem.
+func statFile(name string) (fs.FileInfo, error) {
+	if _fs == nil {
+		return os.Stat(name)
+	}
+	return fs.Stat(_fs, name)
+}
+
+// joinPath() joins path elements using the cache's filesystem's separator.
+func joinPath(elem ...string) string {
+	if _fs == nil {
+		return filepath.Join(elem...)
+	}
+	return path.Join(elem...)
+}
+
+// dirPath() returns the directory part of a path in the cache's filesystem.
+func dirPath(name string) string {
+	if _fs == nil {
+		return filepath.Dir(name)
+	}
+	return path.Dir(name)
+}
+
+// walkFiles() calls f with the path of every file under root in the
+// cache's filesystem and its slash-separated path relative to root.
+// Anything that can't be walked is passed to fail instead of stopping
+// the walk.
+func walkFiles(root string, f func(path, key string), fail func(path string, err error)) {
+	walk := func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			fail(name, err)
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			rel, _ := filepath.Rel(filepath.FromSlash(root), filepath.FromSlash(name))
+			f(name, filepath.ToSlash(rel))
+		}
+		return nil
+	}
+	if _fs == nil {
+		filepath.WalkDir(root, walk)
+	} else {
+		fs.WalkDir(_fs, root, walk)
+	}
+}
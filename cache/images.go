@@ -3,12 +3,8 @@ package cache
 import (
 	"fmt"
 	"github.com/dradtke/go-allegro/allegro"
-	"os"
-	"path/filepath"
 )
 
-var _images = make(map[string]*allegro.Bitmap)
-
 type ImageNotFound struct {
 	Key string
 }
@@ -17,45 +13,56 @@ func (e *ImageNotFound) Error() string {
 	return fmt.Sprintf("image %s not found", e.Key)
 }
 
-// ClearImages() removes all images from the cache.
+// ClearImages() removes all images from the cache. Sub-bitmaps,
+// such as the frames of a sprite sheet, are destroyed first.
 func ClearImages() {
-	for key, val := range _images {
-		val.Destroy()
-		delete(_images, key)
-	}
+	ClearResources(ImageResource)
 }
 
-// LoadImage() loads an image into the cache.
+// LoadImage() loads an image into the cache. If an image is already
+// cached under key, it gains a reference instead of being loaded again.
 func LoadImage(path, key string) error {
-	bmp, err := allegro.LoadBitmap(path)
-	if err != nil {
-		return err
-	}
 	if key == "" {
 		key = path
 	}
-	_images[key] = bmp
-	return nil
+	return load(ImageResource, key, path, func() (interface{}, error) {
+		return openResource(ImageResource, path, 0)
+	})
+}
+
+// ReleaseImage() releases a reference to an image, destroying it
+// if it was the last one.
+func ReleaseImage(key string) error {
+	return Release(ImageResource, key)
 }
 
-// LoadImages() walks root recursively loading all the images that it can.
-// It returns the first error encountered, which may or may not be meaningful
-// depending on whether or not root contains non-image files.
+// LoadImages() walks root recursively loading all the images that it can,
+// keyed by their path relative to root. A file that fails to load doesn't
+// stop the walk; if any do, an error of type LoadErrors is returned listing
+// each of them, which may or may not be meaningful depending on whether or
+// not root contains non-image files. To load images without blocking, use
+// a Loader instead.
 func LoadImages(root string) error {
-	root_len := len(root)
-	return filepath.Walk(root, func(path string, info os.FileInfo, _ error) error {
-		if info.IsDir() {
-			return nil
+	var errors LoadErrors
+	fail := func(path string, err error) {
+		errors = append(errors, &LoadError{path, err})
+	}
+	walkFiles(root, func(path, key string) {
+		if err := LoadImage(path, key); err != nil {
+			fail(path, err)
 		}
-		return LoadImage(path, path[root_len+1:])
-	})
+	}, fail)
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
 }
 
 // FindImage() finds an image in the cache. If it
 // doesn't exist, an error of type ImageNotFound is returned.
 func FindImage(key string) (*allegro.Bitmap, error) {
-	if bmp, ok := _images[key]; ok {
-		return bmp, nil
+	if bmp, ok := find(ImageResource, key); ok {
+		return bmp.(*allegro.Bitmap), nil
 	}
 	return nil, &ImageNotFound{key}
 }
@@ -69,3 +76,31 @@ func Image(key string) *allegro.Bitmap {
 	}
 	return bmp
 }
+
+// subImage() returns the sub-bitmap covering area of the cached image
+// stored under sheet, which is cached under key. If it's already cached,
+// it gains a reference and is reused rather than replaced, since clips
+// may still be drawing it; a different image cached under key is an
+// error. The sheet is kept alive for as long as the sub-bitmap is.
+func subImage(sheet, key string, area Region) (*allegro.Bitmap, error) {
+	_resourcesMutex.Lock()
+	defer _resourcesMutex.Unlock()
+	parent, ok := _resources[ImageResource][sheet]
+	if !ok {
+		return nil, &ImageNotFound{sheet}
+	}
+	if r, ok := _resources[ImageResource][key]; ok {
+		if r.parent != parent || r.area != area {
+			return nil, fmt.Errorf("image %s is already cached, and isn't the same part of %s", key, sheet)
+		}
+		r.Refs++
+		return r.value.(*allegro.Bitmap), nil
+	}
+	bmp := parent.value.(*allegro.Bitmap).CreateSubBitmap(area.X, area.Y, area.W, area.H)
+	if bmp == nil {
+		return nil, fmt.Errorf("failed to create a %dx%d sub-bitmap of %s at %d,%d", area.W, area.H, sheet, area.X, area.Y)
+	}
+	r := add(ImageResource, key, "", bmp, parent)
+	r.area = area
+	return bmp, nil
+}
@@ -0,0 +1,261 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/dradtke/go-allegro/allegro"
+	"runtime"
+	"sync"
+)
+
+// LoadError is the error for a single file that failed to load.
+type LoadError struct {
+	Path string
+	Err  error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("failed to load %s: %s", e.Path, e.Err)
+}
+
+// LoadErrors is a list of files that failed to load.
+type LoadErrors []*LoadError
+
+func (e LoadErrors) Error() string {
+	switch len(e) {
+	case 0:
+		return "no errors"
+	case 1:
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more)", e[0], len(e)-1)
+}
+
+// loadJob is a single resource to be loaded by a Loader.
+type loadJob struct {
+	kind      ResourceKind
+	path, key string
+	size      int // for fonts
+	value     interface{}
+	err       error
+}
+
+// Loader loads resources into the cache in the background. Files are read
+// and decoded on a separate goroutine, and then uploaded to the cache from
+// the main thread by calling Poll() each frame, so the window stays
+// responsive. A file that fails to load doesn't stop the others; its error
+// is collected and can be retrieved with Err().
+type Loader struct {
+	mutex   sync.Mutex
+	jobs    []*loadJob
+	ready   []*loadJob
+	done    int
+	errors  LoadErrors
+	started bool
+	packed  bool
+
+	// Atlas, if set, causes the images loaded by the loader
+	// to be packed into atlases once they've all loaded.
+	Atlas *AtlasOptions
+}
+
+// NewLoader() creates an empty loader.
+func NewLoader() *Loader {
+	return new(Loader)
+}
+
+// AddImage() queues an image to be loaded under key.
+func (l *Loader) AddImage(path, key string) {
+	l.add(&loadJob{kind: ImageResource, path: path, key: key})
+}
+
+// AddImages() queues every file under root to be loaded as an image,
+// keyed by its path relative to root, like LoadImages().
+func (l *Loader) AddImages(root string) {
+	walkFiles(root, l.AddImage, func(path string, err error) {
+		l.fail(&LoadError{path, err})
+	})
+}
+
+// AddFont() queues a font to be loaded at the given size under key.
+func (l *Loader) AddFont(path string, size int, key string) {
+	l.add(&loadJob{kind: FontResource, path: path, key: key, size: size})
+}
+
+// AddSample() queues an audio sample to be loaded under key.
+func (l *Loader) AddSample(path, key string) {
+	l.add(&loadJob{kind: SampleResource, path: path, key: key})
+}
+
+// AddConfig() queues a config file to be loaded under key.
+func (l *Loader) AddConfig(path, key string) {
+	l.add(&loadJob{kind: ConfigResource, path: path, key: key})
+}
+
+// AddData() queues a file to be read under key.
+func (l *Loader) AddData(path, key string) {
+	l.add(&loadJob{kind: DataResource, path: path, key: key})
+}
+
+// Start() starts loading everything that's been queued in the
+// background. Anything queued afterwards is ignored. Calling it
+// more than once has no effect.
+func (l *Loader) Start() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.started {
+		return
+	}
+	l.started = true
+	go l.run(l.jobs)
+}
+
+// Poll() adds everything that's been decoded since the last call to
+// the cache, returning true once everything has been loaded or failed.
+// It must be called from the main thread.
+func (l *Loader) Poll() bool {
+	l.mutex.Lock()
+	ready := l.ready
+	l.ready = nil
+	l.mutex.Unlock()
+
+	for _, job := range ready {
+		if job.err == nil {
+			job.err = job.upload()
+		}
+		l.mutex.Lock()
+		l.done++
+		if job.err != nil {
+			l.errors = append(l.errors, &LoadError{job.path, job.err})
+		}
+		l.mutex.Unlock()
+	}
+	if !l.Done() {
+		return false
+	}
+	if l.Atlas != nil && !l.packed {
+		l.pack()
+	}
+	return true
+}
+
+// pack() packs the images that the loader loaded into atlases.
+func (l *Loader) pack() {
+	l.packed = true
+	keys := make([]string, 0)
+	for _, job := range l.jobs {
+		if job.kind == ImageResource && job.err == nil {
+			keys = append(keys, job.key)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	if _, err := PackImages(*l.Atlas, keys...); err != nil {
+		l.fail(&LoadError{AtlasKeyPrefix, err})
+	}
+}
+
+// Progress() returns how much of the loading is done, from 0 to 1.
+func (l *Loader) Progress() float32 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.jobs) == 0 {
+		return 1
+	}
+	return float32(l.done) / float32(len(l.jobs))
+}
+
+// Done() returns true if the loader has started and every
+// resource has been either loaded or failed.
+func (l *Loader) Done() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.started && l.done >= len(l.jobs)
+}
+
+// Err() returns an error of type LoadErrors if anything
+// has failed to load so far, otherwise nil.
+func (l *Loader) Err() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.errors) == 0 {
+		return nil
+	}
+	errors := make(LoadErrors, len(l.errors))
+	copy(errors, l.errors)
+	return errors
+}
+
+// add() queues a job, unless the loader has already started.
+func (l *Loader) add(job *loadJob) {
+	if job.key == "" {
+		job.key = job.path
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !l.started {
+		l.jobs = append(l.jobs, job)
+	}
+}
+
+// fail() records an error that isn't tied to a queued job.
+func (l *Loader) fail(err *LoadError) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.errors = append(l.errors, err)
+}
+
+// run() decodes each job in turn, handing them off to be uploaded.
+func (l *Loader) run(jobs []*loadJob) {
+	// New bitmap flags are per-thread, so the goroutine has to
+	// stay on one thread for the memory bitmap flag to stick.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	allegro.SetNewBitmapFlags(allegro.MEMORY_BITMAP)
+
+	for _, job := range jobs {
+		job.decode()
+		l.mutex.Lock()
+		l.ready = append(l.ready, job)
+		l.mutex.Unlock()
+	}
+}
+
+// decode() reads the job's file into memory. Images are decoded into
+// memory bitmaps, and fonts are left for the main thread because their
+// glyphs are cached in video bitmaps.
+func (job *loadJob) decode() {
+	if job.kind != FontResource {
+		job.value, job.err = job.open()
+	}
+}
+
+// open() loads the job's file. When called from the main thread,
+// images are loaded into video bitmaps.
+func (job *loadJob) open() (interface{}, error) {
+	return openResource(job.kind, job.path, job.size)
+}
+
+// upload() finishes loading a decoded job and adds it to the cache.
+// It must be called from the main thread.
+func (job *loadJob) upload() error {
+	key := job.key
+	switch job.kind {
+	case ImageResource:
+		mem := job.value.(*allegro.Bitmap)
+		bmp, err := mem.Clone()
+		mem.Destroy()
+		if err != nil {
+			return err
+		}
+		job.value = bmp
+	case FontResource:
+		f, err := job.open()
+		if err != nil {
+			return err
+		}
+		job.value, key = f, FontKey(job.key, job.size)
+	}
+	put(job.kind, key, job.path, job.value, job.open)
+	return nil
+}
@@ -0,0 +1,328 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Manifest lists the assets that a game needs, so that they can be checked
+// up front instead of failing when they're first used. It's stored as JSON:
+//
+//	{
+//		"root": "data",
+//		"assets": [
+//			{"kind": "config", "path": "data/game.cfg", "key": "game"},
+//			{"kind": "image", "path": "data/images", "files": ["walking-{1-9}.png"]},
+//			{"kind": "sample", "path": "data/sounds/*.ogg", "count": 4},
+//			{"kind": "font", "path": "data/fonts/title.ttf", "size": 24}
+//		],
+//		"ignore": ["*.md", "data/sources/*"]
+//	}
+//
+// Each asset's path names a single file, a directory, meaning every file
+// under it, or a glob pattern. Paths are relative to the cache's filesystem.
+// A directory or pattern only needs to match something to count as present,
+// so list the files that it must contain, or the least number of files it
+// must match, to catch a single one going missing.
+type Manifest struct {
+	// Root is the directory that's searched for files that aren't
+	// referenced by any asset. It defaults to the current directory.
+	Root string `json:"root"`
+
+	Assets []ManifestAsset `json:"assets"`
+
+	// Ignore lists glob patterns for files under Root that aren't assets,
+	// and so shouldn't be reported as unreferenced. A pattern without a
+	// slash is matched against file names, otherwise against whole paths.
+	Ignore []string `json:"ignore"`
+
+	// path is the file that the manifest was loaded from, if any.
+	path string
+}
+
+// ManifestAsset is a single entry in a manifest.
+type ManifestAsset struct {
+	Kind ResourceKind `json:"kind"`
+	Path string       `json:"path"`
+
+	// Key is the key that the asset is meant to be cached under.
+	Key string `json:"key,omitempty"`
+
+	// Size is the size that a font is loaded at.
+	Size int `json:"size,omitempty"`
+
+	// Expected lists files that a directory asset must contain, relative
+	// to the directory. A name can include a range of numbers in braces,
+	// such as walking-{1-9}.png, which expands to a name for each one.
+	Expected []string `json:"files,omitempty"`
+
+	// Count is the least number of files that a directory
+	// or pattern asset must match.
+	Count int `json:"count,omitempty"`
+
+	// Optional assets aren't reported as missing.
+	Optional bool `json:"optional,omitempty"`
+}
+
+// ManifestReport is the result of checking a manifest against the files
+// that are actually there. It implements error, so that a report that
+// isn't OK() can be returned or shown as one.
+type ManifestReport struct {
+	// Missing lists the paths of required assets that don't exist,
+	// including patterns that didn't match anything.
+	Missing []string
+
+	// Unreadable lists the assets that exist but couldn't be loaded.
+	Unreadable LoadErrors
+
+	// Unreferenced lists the files under the manifest's root that
+	// aren't referenced by any asset, which are probably either
+	// leftovers or assets that were forgotten about.
+	Unreferenced []string
+
+	// Checked is the number of files that were checked.
+	Checked int
+}
+
+// OK() returns true if nothing is missing or unreadable.
+// Unreferenced files don't count.
+func (r *ManifestReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Unreadable) == 0
+}
+
+// Error() returns the report as a readable, multi-line summary.
+func (r *ManifestReport) Error() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "checked %d asset files: %d missing, %d unreadable, %d unreferenced",
+		r.Checked, len(r.Missing), len(r.Unreadable), len(r.Unreferenced))
+	if len(r.Missing) > 0 {
+		b.WriteString("\n\nMissing:")
+		for _, p := range r.Missing {
+			b.WriteString("\n  " + p)
+		}
+	}
+	if len(r.Unreadable) > 0 {
+		b.WriteString("\n\nUnreadable:")
+		for _, err := range r.Unreadable {
+			fmt.Fprintf(&b, "\n  %s: %s", err.Path, err.Err)
+		}
+	}
+	if len(r.Unreferenced) > 0 {
+		b.WriteString("\n\nUnreferenced:")
+		for _, p := range r.Unreferenced {
+			b.WriteString("\n  " + p)
+		}
+	}
+	return b.String()
+}
+
+// ParseManifest() parses a manifest from JSON.
+func ParseManifest(data []byte) (*Manifest, error) {
+	m := new(Manifest)
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	for i, asset := range m.Assets {
+		if asset.Path == "" {
+			return nil, fmt.Errorf("manifest asset %d has no path", i)
+		}
+		if len(asset.Expected) > 0 && strings.ContainsAny(asset.Path, "*?[") {
+			return nil, fmt.Errorf("manifest asset %s lists files, but isn't a directory", asset.Path)
+		}
+		for _, name := range asset.Expected {
+			if _, err := expandName(name); err != nil {
+				return nil, fmt.Errorf("manifest asset %s: %s", asset.Path, err)
+			}
+		}
+	}
+	return m, nil
+}
+
+// LoadManifest() reads a manifest from the cache's filesystem.
+func LoadManifest(name string) (*Manifest, error) {
+	data, err := ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	m, err := ParseManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	m.path = name
+	return m, nil
+}
+
+// Files() returns the files that an asset refers to,
+// or an empty slice if none of them exist.
+func (a ManifestAsset) Files() []string {
+	if strings.ContainsAny(a.Path, "*?[") {
+		var (
+			matches []string
+			err     error
+		)
+		if _fs == nil {
+			matches, err = filepath.Glob(a.Path)
+		} else {
+			matches, err = fs.Glob(_fs, a.Path)
+		}
+		if err != nil {
+			return nil
+		}
+		files := make([]string, 0, len(matches))
+		for _, match := range matches {
+			if info, err := statFile(match); err == nil && !info.IsDir() {
+				files = append(files, match)
+			}
+		}
+		return files
+	}
+	info, err := statFile(a.Path)
+	if err != nil {
+		return nil
+	}
+	if !info.IsDir() {
+		return []string{a.Path}
+	}
+	files := make([]string, 0)
+	walkFiles(a.Path, func(path, key string) {
+		files = append(files, path)
+	}, func(path string, err error) {})
+	return files
+}
+
+// Check() checks that every asset in the manifest exists and can be read,
+// and looks for files under the manifest's root that aren't referenced.
+// If decode is true, each file is also loaded as its kind of resource and
+// then thrown away, which needs the relevant Allegro addons installed;
+// otherwise files are only read.
+func (m *Manifest) Check(decode bool) *ManifestReport {
+	r := new(ManifestReport)
+	referenced := make(map[string]bool)
+	if m.path != "" {
+		referenced[cleanPath(m.path)] = true
+	}
+	for _, asset := range m.Assets {
+		files := asset.Files()
+		if !asset.Optional {
+			r.Missing = append(r.Missing, asset.missing(files)...)
+		}
+		for _, file := range files {
+			if referenced[cleanPath(file)] {
+				continue
+			}
+			referenced[cleanPath(file)] = true
+			r.Checked++
+			if err := checkFile(asset, file, decode); err != nil {
+				r.Unreadable = append(r.Unreadable, &LoadError{file, err})
+			}
+		}
+	}
+
+	root := m.Root
+	if root == "" {
+		root = "."
+	}
+	walkFiles(root, func(file, key string) {
+		if !referenced[cleanPath(file)] && !m.ignored(file) {
+			r.Unreferenced = append(r.Unreferenced, file)
+		}
+	}, func(file string, err error) {
+		r.Unreadable = append(r.Unreadable, &LoadError{file, err})
+	})
+	sort.Strings(r.Unreferenced)
+	return r
+}
+
+// missing() returns the paths of whatever the asset
+// requires that isn't among the files it matched.
+func (a ManifestAsset) missing(files []string) []string {
+	if len(files) == 0 {
+		return []string{a.Path}
+	}
+	missing := make([]string, 0)
+	if len(files) < a.Count {
+		missing = append(missing, fmt.Sprintf("%s (%d of %d files)", a.Path, len(files), a.Count))
+	}
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		present[cleanPath(file)] = true
+	}
+	for _, pattern := range a.Expected {
+		names, _ := expandName(pattern)
+		for _, name := range names {
+			if file := joinPath(a.Path, name); !present[cleanPath(file)] {
+				missing = append(missing, file)
+			}
+		}
+	}
+	return missing
+}
+
+// expandName() expands a range of numbers in braces,
+// such as walking-{1-9}.png, into a name for each one.
+func expandName(name string) ([]string, error) {
+	start := strings.Index(name, "{")
+	if start < 0 {
+		return []string{name}, nil
+	}
+	end := strings.Index(name[start:], "}")
+	if end < 0 {
+		return nil, fmt.Errorf("unclosed range in %q", name)
+	}
+	end += start
+	indices, err := parseRanges(name[start+1 : end])
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(indices))
+	for i, index := range indices {
+		names[i] = name[:start] + strconv.Itoa(index) + name[end+1:]
+	}
+	return names, nil
+}
+
+// ignored() returns true if file matches one of the manifest's ignore patterns.
+func (m *Manifest) ignored(file string) bool {
+	file = cleanPath(file)
+	for _, pattern := range m.Ignore {
+		name := file
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(file)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFile() reads, and optionally decodes, a single file.
+func checkFile(asset ManifestAsset, file string, decode bool) error {
+	if !decode || asset.Kind == DataResource {
+		_, err := ReadFile(file)
+		return err
+	}
+	size := asset.Size
+	if asset.Kind == FontResource && size == 0 {
+		size = 12
+	}
+	value, err := openResource(asset.Kind, file, size)
+	if err != nil {
+		return err
+	}
+	destroyValue(value)
+	return nil
+}
+
+// cleanPath() returns a path in a form that can be compared, regardless
+// of which filesystem it came from.
+func cleanPath(p string) string {
+	return path.Clean(filepath.ToSlash(p))
+}
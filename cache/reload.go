@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"github.com/dradtke/go-allegro/allegro"
+	"time"
+)
+
+// ReloadModified() checks the modification time of every file that's been
+// loaded into the cache, reloads the ones that have changed, and returns
+// a description of each resource that was reloaded. Files that fail to
+// reload are returned as an error of type LoadErrors, and keep their
+// current value. It must be called from the main thread.
+//
+// Reloaded resources keep their keys, so anything that looks them up by
+// key gets the new version. Images that are still the same size are
+// redrawn in place, so bitmaps that are held directly, such as the frames
+// of an animation clip or the sub-bitmaps of a sheet, update too. Any
+// other value is replaced, and the old one is left alone in case it's
+// still in use; hot reloading is only meant for development.
+func ReloadModified() ([]ResourceInfo, error) {
+	_resourcesMutex.Lock()
+	candidates := make([]*resource, 0)
+	for _, resources := range _resources {
+		for _, r := range resources {
+			if r.open != nil && r.Path != "" {
+				candidates = append(candidates, r)
+			}
+		}
+	}
+	_resourcesMutex.Unlock()
+
+	reloaded := make([]ResourceInfo, 0)
+	var errors LoadErrors
+	for _, r := range candidates {
+		mod := modTime(r.Path)
+		if mod.IsZero() || !mod.After(r.modTime) {
+			continue
+		}
+		if err := reload(r, mod); err != nil {
+			errors = append(errors, &LoadError{r.Path, err})
+			continue
+		}
+		reloaded = append(reloaded, r.ResourceInfo)
+	}
+	if len(errors) > 0 {
+		return reloaded, errors
+	}
+	return reloaded, nil
+}
+
+// Reload() loads a resource again from its file, regardless of whether
+// or not it's changed, in the same way as ReloadModified(). It must be
+// called from the main thread.
+func Reload(kind ResourceKind, key string) error {
+	_resourcesMutex.Lock()
+	r, ok := _resources[kind][key]
+	_resourcesMutex.Unlock()
+	if !ok || r.open == nil {
+		return notFound(kind, key)
+	}
+	return reload(r, modTime(r.Path))
+}
+
+// reload() loads the resource again, and records the time that
+// its file was modified.
+func reload(r *resource, mod time.Time) error {
+	value, err := r.open()
+	if err != nil {
+		// Don't keep trying until the file changes again.
+		r.modTime = mod
+		return err
+	}
+
+	_resourcesMutex.Lock()
+	defer _resourcesMutex.Unlock()
+	r.modTime = mod
+	if old, ok := r.value.(*allegro.Bitmap); ok {
+		if bmp := value.(*allegro.Bitmap); bmp.Width() == old.Width() && bmp.Height() == old.Height() {
+			old.AsTarget(func() {
+				allegro.ClearToColor(allegro.MapRGBA(0, 0, 0, 0))
+				bmp.Draw(0, 0, allegro.FLIP_NONE)
+			})
+			bmp.Destroy()
+			return nil
+		}
+	}
+	r.value = value
+	return nil
+}
+
+// modTime() returns the time that the file at path was last
+// modified, or the zero time if it can't be found.
+func modTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := statFile(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,317 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/dradtke/go-allegro/allegro"
+	"github.com/dradtke/go-allegro/allegro/audio"
+	"github.com/dradtke/go-allegro/allegro/font"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ResourceKind is the type of a cached resource.
+type ResourceKind int
+
+const (
+	ImageResource ResourceKind = iota
+	FontResource
+	SampleResource
+	ConfigResource
+	DataResource
+)
+
+func (k ResourceKind) String() string {
+	switch k {
+	case ImageResource:
+		return "image"
+	case FontResource:
+		return "font"
+	case SampleResource:
+		return "sample"
+	case ConfigResource:
+		return "config"
+	case DataResource:
+		return "data"
+	}
+	return fmt.Sprintf("ResourceKind(%d)", int(k))
+}
+
+// ParseResourceKind() parses the name of a resource kind,
+// as returned by String().
+func ParseResourceKind(s string) (ResourceKind, error) {
+	for k := ImageResource; k <= DataResource; k++ {
+		if k.String() == s {
+			return k, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown resource kind: %s", s)
+}
+
+func (k ResourceKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *ResourceKind) UnmarshalText(text []byte) error {
+	kind, err := ParseResourceKind(string(text))
+	if err != nil {
+		return err
+	}
+	*k = kind
+	return nil
+}
+
+// ResourceInfo describes a resource in the cache.
+type ResourceInfo struct {
+	Kind ResourceKind
+	Key  string
+
+	// Path is the file that the resource was loaded from,
+	// or empty if it wasn't loaded from a file.
+	Path string
+
+	// Refs is the number of users holding on to the resource.
+	Refs int
+}
+
+// resource is an entry in the cache.
+type resource struct {
+	ResourceInfo
+	value interface{}
+
+	// parent is the resource that this one depends on, such as the
+	// sheet that a sub-bitmap was sliced from. It's kept alive for as
+	// long as this resource is.
+	parent *resource
+
+	// area is the part of the parent that a sub-bitmap covers.
+	area Region
+
+	// open loads the resource again from its path, and modTime is the
+	// modification time of the file when it was last loaded. They're
+	// used for hot reloading.
+	open    func() (interface{}, error)
+	modTime time.Time
+}
+
+var (
+	_resources      = make(map[ResourceKind]map[string]*resource)
+	_resourcesMutex sync.Mutex
+)
+
+type ResourceNotFound struct {
+	Kind ResourceKind
+	Key  string
+}
+
+func (e *ResourceNotFound) Error() string {
+	return fmt.Sprintf("%s %s not found", e.Kind, e.Key)
+}
+
+// Retain() adds a reference to a resource, so that it isn't destroyed until
+// Release() has been called once more than Retain(). Resources start with
+// one reference, held by whoever loaded them, and loading a resource that's
+// already in the cache adds another reference instead of loading it again.
+func Retain(kind ResourceKind, key string) error {
+	_resourcesMutex.Lock()
+	defer _resourcesMutex.Unlock()
+	r, ok := _resources[kind][key]
+	if !ok {
+		return notFound(kind, key)
+	}
+	r.Refs++
+	return nil
+}
+
+// Release() removes a reference to a resource, destroying
+// it and removing it from the cache if it was the last one.
+func Release(kind ResourceKind, key string) error {
+	_resourcesMutex.Lock()
+	defer _resourcesMutex.Unlock()
+	r, ok := _resources[kind][key]
+	if !ok {
+		return notFound(kind, key)
+	}
+	release(r)
+	return nil
+}
+
+// Resources() returns a description of every resource in
+// the cache, ordered by kind and then by key.
+func Resources() []ResourceInfo {
+	_resourcesMutex.Lock()
+	defer _resourcesMutex.Unlock()
+	infos := make([]ResourceInfo, 0)
+	for _, resources := range _resources {
+		for _, r := range resources {
+			infos = append(infos, r.ResourceInfo)
+		}
+	}
+	sort.Sort(byKindAndKey(infos))
+	return infos
+}
+
+// ClearResources() destroys every resource of the given kind and removes
+// them from the cache, regardless of how many references they have.
+func ClearResources(kind ResourceKind) {
+	_resourcesMutex.Lock()
+	defer _resourcesMutex.Unlock()
+	for _, r := range _resources[kind] {
+		if _, ok := _resources[kind][r.Key]; ok {
+			destroy(r)
+		}
+	}
+}
+
+// ClearAll() destroys every resource and empties the cache.
+func ClearAll() {
+	for _, kind := range []ResourceKind{ImageResource, FontResource, SampleResource, ConfigResource, DataResource} {
+		ClearResources(kind)
+	}
+	ClearClips()
+}
+
+// load() adds a reference to the resource if it's already in the cache,
+// or calls open to load it and adds it with one reference. open is also
+// used to load it again if it's hot reloaded.
+func load(kind ResourceKind, key, path string, open func() (interface{}, error)) error {
+	_resourcesMutex.Lock()
+	if r, ok := _resources[kind][key]; ok {
+		r.Refs++
+		_resourcesMutex.Unlock()
+		return nil
+	}
+	_resourcesMutex.Unlock()
+
+	value, err := open()
+	if err != nil {
+		return err
+	}
+	put(kind, key, path, value, open)
+	return nil
+}
+
+// put() adds a loaded value to the cache with one reference. If the
+// resource was loaded by someone else in the meantime, value is destroyed
+// and the existing resource gains a reference instead, and false is
+// returned.
+func put(kind ResourceKind, key, path string, value interface{}, open func() (interface{}, error)) bool {
+	_resourcesMutex.Lock()
+	defer _resourcesMutex.Unlock()
+	if r, ok := _resources[kind][key]; ok {
+		destroyValue(value)
+		r.Refs++
+		return false
+	}
+	r := add(kind, key, path, value, nil)
+	r.open, r.modTime = open, modTime(path)
+	return true
+}
+
+// find() returns the value of a resource, or nil if it isn't cached.
+func find(kind ResourceKind, key string) (interface{}, bool) {
+	_resourcesMutex.Lock()
+	defer _resourcesMutex.Unlock()
+	if r, ok := _resources[kind][key]; ok {
+		return r.value, true
+	}
+	return nil, false
+}
+
+// resourceKeys() returns the keys of every resource of the given kind.
+func resourceKeys(kind ResourceKind) []string {
+	_resourcesMutex.Lock()
+	defer _resourcesMutex.Unlock()
+	keys := make([]string, 0, len(_resources[kind]))
+	for key := range _resources[kind] {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// add() adds a resource with one reference, replacing and destroying
+// any existing resource with the same key. If parent isn't nil, the
+// new resource holds a reference to it. The mutex must be held.
+func add(kind ResourceKind, key, path string, value interface{}, parent *resource) *resource {
+	if _resources[kind] == nil {
+		_resources[kind] = make(map[string]*resource)
+	}
+	if old, ok := _resources[kind][key]; ok {
+		destroy(old)
+	}
+	r := &resource{ResourceInfo: ResourceInfo{Kind: kind, Key: key, Path: path, Refs: 1}, value: value, parent: parent}
+	if parent != nil {
+		parent.Refs++
+	}
+	_resources[kind][key] = r
+	return r
+}
+
+// release() removes a reference to a resource, destroying it
+// if it was the last one. The mutex must be held.
+func release(r *resource) {
+	if r.Refs--; r.Refs <= 0 {
+		destroy(r)
+	}
+}
+
+// destroy() removes a resource from the cache and destroys it, along
+// with any resources that depend on it. The mutex must be held.
+func destroy(r *resource) {
+	if _resources[r.Kind][r.Key] != r {
+		return
+	}
+	delete(_resources[r.Kind], r.Key)
+	for _, resources := range _resources {
+		for _, child := range resources {
+			if child.parent == r {
+				child.parent = nil
+				destroy(child)
+			}
+		}
+	}
+	destroyValue(r.value)
+	if r.parent != nil {
+		release(r.parent)
+	}
+}
+
+// destroyValue() frees whatever memory is held by a resource's value.
+func destroyValue(value interface{}) {
+	switch v := value.(type) {
+	case *allegro.Bitmap:
+		v.Destroy()
+	case *font.Font:
+		v.Destroy()
+	case *audio.Sample:
+		v.Destroy()
+	case *allegro.Config:
+		v.Destroy()
+	}
+}
+
+// notFound() returns the typed error for a missing resource.
+func notFound(kind ResourceKind, key string) error {
+	switch kind {
+	case ImageResource:
+		return &ImageNotFound{key}
+	case SampleResource:
+		return &SampleNotFound{key}
+	case ConfigResource:
+		return &ConfigNotFound{key}
+	case DataResource:
+		return &DataNotFound{key}
+	}
+	return &ResourceNotFound{kind, key}
+}
+
+type byKindAndKey []ResourceInfo
+
+func (s byKindAndKey) Len() int      { return len(s) }
+func (s byKindAndKey) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byKindAndKey) Less(i, j int) bool {
+	if s[i].Kind != s[j].Kind {
+		return s[i].Kind < s[j].Kind
+	}
+	return s[i].Key < s[j].Key
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"fmt"
+	"github.com/dradtke/go-allegro/allegro/audio"
+)
+
+type SampleNotFound struct {
+	Key string
+}
+
+func (e *SampleNotFound) Error() string {
+	return fmt.Sprintf("sample %s not found", e.Key)
+}
+
+// LoadSample() loads an audio sample into the cache. If a sample is already
+// cached under key, it gains a reference instead of being loaded again.
+func LoadSample(path, key string) error {
+	if key == "" {
+		key = path
+	}
+	return load(SampleResource, key, path, func() (interface{}, error) {
+		return openResource(SampleResource, path, 0)
+	})
+}
+
+// ReleaseSample() releases a reference to a sample, destroying it
+// if it was the last one.
+func ReleaseSample(key string) error {
+	return Release(SampleResource, key)
+}
+
+// FindSample() finds a sample in the cache. If it
+// doesn't exist, an error of type SampleNotFound is returned.
+func FindSample(key string) (*audio.Sample, error) {
+	if s, ok := find(SampleResource, key); ok {
+		return s.(*audio.Sample), nil
+	}
+	return nil, &SampleNotFound{key}
+}
+
+// Sample() gets a sample from the cache using FindSample(),
+// panicking if it isn't found.
+func Sample(key string) *audio.Sample {
+	s, err := FindSample(key)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
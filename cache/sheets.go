@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/dradtke/allegory/config"
+	"github.com/dradtke/go-allegro/allegro"
+)
+
+// sheetData is the JSON metadata exported alongside a sprite sheet by
+// Aseprite or TexturePacker. Frames is either an object keyed by frame
+// name (the "hash" format) or a list of frames (the "array" format).
+type sheetData struct {
+	Frames json.RawMessage `json:"frames"`
+	Meta   struct {
+		Image     string `json:"image"`
+		FrameTags []struct {
+			Name      string `json:"name"`
+			From      int    `json:"from"`
+			To        int    `json:"to"`
+			Direction string `json:"direction"`
+		} `json:"frameTags"`
+	} `json:"meta"`
+}
+
+// sheetFrame is a single frame's entry in the metadata.
+type sheetFrame struct {
+	Filename string `json:"filename"`
+	Frame    Region `json:"frame"`
+	Rotated  bool   `json:"rotated"`
+	Duration int    `json:"duration"` // in milliseconds
+}
+
+// LoadAseprite() loads a sprite sheet exported from Aseprite along with
+// its JSON metadata, in either the hash or array format. The sheet is
+// added to the cache under key, or under the image path given in the
+// metadata if key is empty, and each frame is added as a sub-bitmap
+// under its frame name.
+//
+// Each tag becomes an animation clip with the same name, respecting
+// its direction and the duration of each frame. If there aren't any
+// tags, a single looping clip named after the sheet's key is made
+// from all of the frames. Clips can be played by passing them to an
+// AnimationProcess.
+func LoadAseprite(path, key string) error {
+	data, frames, images, err := loadSheet(path, key)
+	if err != nil {
+		return err
+	}
+	clipFrames := make([]Frame, len(frames))
+	for i, frame := range frames {
+		clipFrames[i] = Frame{Image: images[i], Duration: msToTicks(frame.Duration)}
+	}
+
+	if len(data.Meta.FrameTags) == 0 {
+		if key == "" {
+			key = data.Meta.Image
+		}
+		AddClip(&AnimationClip{Name: key, Frames: clipFrames, Mode: PlayLoop})
+		return nil
+	}
+	for _, tag := range data.Meta.FrameTags {
+		if tag.From < 0 || tag.To >= len(clipFrames) || tag.From > tag.To {
+			return fmt.Errorf("%s: tag %s has invalid frames %d-%d", path, tag.Name, tag.From, tag.To)
+		}
+		clip := &AnimationClip{Name: tag.Name, Mode: PlayLoop}
+		clip.Frames = append(clip.Frames, clipFrames[tag.From:tag.To+1]...)
+		switch tag.Direction {
+		case "", "forward":
+		case "reverse":
+			reverseFrames(clip.Frames)
+		case "pingpong":
+			clip.Mode = PlayPingPong
+		case "pingpong_reverse":
+			reverseFrames(clip.Frames)
+			clip.Mode = PlayPingPong
+		default:
+			return fmt.Errorf("%s: tag %s has unknown direction %q", path, tag.Name, tag.Direction)
+		}
+		AddClip(clip)
+	}
+	return nil
+}
+
+// LoadTexturePacker() loads a sprite sheet exported from TexturePacker
+// along with its JSON metadata, in either the hash or array format. The
+// sheet is added to the cache under key, or under the image path given
+// in the metadata if key is empty, and each frame is added as a
+// sub-bitmap under its frame name. Clips can then be made from the
+// frames using NewClip() or LoadClips().
+//
+// Rotated frames aren't supported, and trimmed frames
+// lose the transparent border that was trimmed off.
+func LoadTexturePacker(path, key string) error {
+	_, _, _, err := loadSheet(path, key)
+	return err
+}
+
+// loadSheet() reads the metadata at path, loads the sheet that it refers
+// to, and slices it into frames, returning the frames in order along
+// with their sub-bitmaps. Loading a sheet again reuses its cached frames,
+// so clips made from it the first time stay valid.
+func loadSheet(path, key string) (*sheetData, []sheetFrame, []*allegro.Bitmap, error) {
+	raw, err := ReadFile(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	data := new(sheetData)
+	if err := json.Unmarshal(raw, data); err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if data.Meta.Image == "" {
+		return nil, nil, nil, fmt.Errorf("%s: no image specified", path)
+	}
+	frames, err := parseSheetFrames(data.Frames)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	if key == "" {
+		key = data.Meta.Image
+	}
+	if err := LoadImage(joinPath(dirPath(path), data.Meta.Image), key); err != nil {
+		return nil, nil, nil, err
+	}
+	images := make([]*allegro.Bitmap, len(frames))
+	for i, frame := range frames {
+		if frame.Rotated {
+			return nil, nil, nil, fmt.Errorf("%s: frame %s is rotated, which isn't supported", path, frame.Filename)
+		}
+		if images[i], err = subImage(key, frame.Filename, frame.Frame); err != nil {
+			return nil, nil, nil, fmt.Errorf("%s: failed to slice frame %s: %s", path, frame.Filename, err)
+		}
+	}
+	return data, frames, images, nil
+}
+
+// parseSheetFrames() parses frames in either the array or hash format.
+// The hash format is read key by key so that the frames stay in the
+// order they were exported in, which is what tags refer to.
+func parseSheetFrames(raw json.RawMessage) ([]sheetFrame, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("no frames")
+	}
+	if raw[0] == '[' {
+		var frames []sheetFrame
+		if err := json.Unmarshal(raw, &frames); err != nil {
+			return nil, err
+		}
+		return frames, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	frames := make([]sheetFrame, 0)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var frame sheetFrame
+		if err := dec.Decode(&frame); err != nil {
+			return nil, err
+		}
+		frame.Filename = tok.(string)
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// msToTicks() converts a duration in milliseconds into the nearest
+// number of ticks at the configured frame rate, but at least 1. A
+// missing duration becomes DefaultFrameDuration.
+func msToTicks(ms int) uint {
+	if ms <= 0 {
+		return DefaultFrameDuration
+	}
+	ticks := (ms*config.Fps() + 500) / 1000
+	if ticks < 1 {
+		return 1
+	}
+	return uint(ticks)
+}
+
+// reverseFrames() reverses the order of the frames in place.
+func reverseFrames(frames []Frame) {
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+}
@@ -0,0 +1,124 @@
+package allegory
+
+import (
+	"sort"
+)
+
+// LayerMask is a set of actor layers. Bit n of the mask corresponds
+// to layer n; layers above 63 are only included in AllLayers.
+type LayerMask uint64
+
+// AllLayers is a mask that includes every layer.
+const AllLayers = ^LayerMask(0)
+
+// Layers() returns a mask that includes each of the provided layers.
+func Layers(layers ...uint) LayerMask {
+	var m LayerMask
+	for _, layer := range layers {
+		if layer < 64 {
+			m |= 1 << layer
+		}
+	}
+	return m
+}
+
+// Has() returns true if the layer is included in the mask.
+func (m LayerMask) Has(layer uint) bool {
+	if layer >= 64 {
+		return m == AllLayers
+	}
+	return m&(1<<layer) != 0
+}
+
+// Rect is an axis-aligned rectangle.
+type Rect struct {
+	X, Y, W, H float32
+}
+
+// Intersects() returns true if the two rectangles overlap.
+func (r Rect) Intersects(o Rect) bool {
+	return r.X < o.X+o.W && o.X < r.X+r.W && r.Y < o.Y+o.H && o.Y < r.Y+r.H
+}
+
+// Camera is a view into the world that gets drawn into one rectangle
+// of the screen. A state with no cameras draws its actors directly
+// onto the display; a state with several, such as one for each player
+// in a split-screen game, draws its actor layers once per camera.
+type Camera struct {
+	// X and Y are the world coordinates shown in the top-left corner
+	// of the viewport.
+	X, Y float32
+
+	// Viewport is the area of the screen that the camera draws into.
+	Viewport Rect
+
+	// Order determines the order in which cameras are drawn.
+	// Cameras with a lower order are drawn first.
+	Order int
+
+	// Layers is the set of actor layers visible to this camera.
+	Layers LayerMask
+}
+
+// NewCamera() creates a camera that draws every layer into
+// the given area of the screen.
+func NewCamera(x, y, w, h float32) *Camera {
+	return &Camera{Viewport: Rect{x, y, w, h}, Layers: AllLayers}
+}
+
+// View() returns the area of the world visible to the camera.
+func (c *Camera) View() Rect {
+	return Rect{c.X, c.Y, c.Viewport.W, c.Viewport.H}
+}
+
+// CenterOn() moves the camera so that the given world
+// coordinates are in the center of its viewport.
+func (c *Camera) CenterOn(x, y float32) {
+	c.X, c.Y = x-c.Viewport.W/2, y-c.Viewport.H/2
+}
+
+// ToWorld() converts screen coordinates inside the camera's
+// viewport to world coordinates.
+func (c *Camera) ToWorld(x, y float32) (float32, float32) {
+	return x - c.Viewport.X + c.X, y - c.Viewport.Y + c.Y
+}
+
+/* -- Related methods -- */
+
+// AddCamera() adds a camera to the current state.
+func AddCamera(cam *Camera) {
+	cur := _state.Current()
+	if cur == nil {
+		return
+	}
+	_cameras[cur] = append(_cameras[cur], cam)
+}
+
+// RemoveCamera() removes a camera from the current state.
+func RemoveCamera(cam *Camera) {
+	cur := _state.Current()
+	if cur == nil {
+		return
+	}
+	cameras := _cameras[cur]
+	for i, c := range cameras {
+		if c == cam {
+			_cameras[cur] = append(cameras[:i], cameras[i+1:]...)
+			break
+		}
+	}
+}
+
+// Cameras() returns a copy of the current state's cameras in render order.
+func Cameras() []*Camera {
+	cameras := make([]*Camera, len(_state.Cameras()))
+	copy(cameras, _state.Cameras())
+	sort.Stable(byOrder(cameras))
+	return cameras
+}
+
+type byOrder []*Camera
+
+func (c byOrder) Len() int           { return len(c) }
+func (c byOrder) Less(i, j int) bool { return c[i].Order < c[j].Order }
+func (c byOrder) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
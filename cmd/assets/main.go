@@ -0,0 +1,96 @@
+// Command assets checks a game's asset manifest, reporting assets that are
+// missing or can't be loaded, and files that aren't referenced by it.
+//
+// Usage:
+//
+//	assets [-decode] [-unreferenced] [-mods dir] <manifest>
+//
+// Paths in the manifest are relative to the current directory. With -decode,
+// every asset is fully loaded as its kind of resource instead of only being
+// read, which catches corrupt files. With -unreferenced, unreferenced files
+// are treated as errors too. With -mods, every directory and zip archive in
+// the given directory is mounted over the current one, as a game would with
+// vfs.FS.MountEach(), so that a mod can be checked against the base data.
+//
+// The exit status is 1 if there were any errors, so it can be used as a
+// build step.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/dradtke/allegory/cache"
+	"github.com/dradtke/allegory/vfs"
+	"github.com/dradtke/go-allegro/allegro"
+	"github.com/dradtke/go-allegro/allegro/acodec"
+	"github.com/dradtke/go-allegro/allegro/audio"
+	"github.com/dradtke/go-allegro/allegro/font"
+	"github.com/dradtke/go-allegro/allegro/image"
+	"github.com/dradtke/go-allegro/allegro/ttf"
+	"os"
+	"runtime"
+)
+
+func main() {
+	var (
+		decode       = flag.Bool("decode", false, "load every asset instead of only reading it")
+		unreferenced = flag.Bool("unreferenced", false, "treat unreferenced files as errors")
+		mods         = flag.String("mods", "", "directory of mods to mount over the base data")
+	)
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: assets [-decode] [-unreferenced] [-mods dir] <manifest>")
+		os.Exit(2)
+	}
+
+	if *mods != "" {
+		fsys := vfs.New()
+		defer fsys.Close()
+		if err := fsys.MountDir("base", ".", vfs.BasePriority); err != nil {
+			fail(err)
+		}
+		if _, err := fsys.MountEach(*mods, vfs.ModPriority); err != nil {
+			fail(err)
+		}
+		cache.SetFS(fsys)
+	}
+
+	if *decode {
+		runtime.LockOSThread()
+		install()
+		// There's no display, so everything has to live in memory.
+		allegro.SetNewBitmapFlags(allegro.MEMORY_BITMAP)
+	}
+
+	m, err := cache.LoadManifest(flag.Arg(0))
+	if err != nil {
+		fail(err)
+	}
+	report := m.Check(*decode)
+	fmt.Println(report.Error())
+	if !report.OK() || (*unreferenced && len(report.Unreferenced) > 0) {
+		os.Exit(1)
+	}
+}
+
+// install() installs the addons needed to decode each kind of asset.
+func install() {
+	if err := image.Install(); err != nil {
+		fail(err)
+	}
+	font.Install()
+	if err := ttf.Install(); err != nil {
+		fail(err)
+	}
+	if err := audio.Install(); err != nil {
+		fail(err)
+	}
+	if err := acodec.Install(); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,69 @@
+// Command atlas packs a directory of images into atlas pages ahead of time,
+// writing each page as a PNG along with a manifest in TexturePacker's JSON
+// hash format, which can be loaded with cache.LoadTexturePacker().
+//
+// Usage:
+//
+//	atlas [-size 1024] [-padding 1] [-out atlas] <image dir>
+//
+// Images are keyed by their path relative to the image directory, so
+// they're found under the same keys as they would be with
+// cache.LoadImages(). The pages are written as atlas-0.png, atlas-0.json,
+// atlas-1.png, and so on, using the name given by -out.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/dradtke/allegory/cache"
+	"github.com/dradtke/go-allegro/allegro"
+	"github.com/dradtke/go-allegro/allegro/image"
+	"os"
+	"runtime"
+)
+
+func main() {
+	var (
+		size    = flag.Int("size", 1024, "width and height of each atlas page")
+		padding = flag.Int("padding", 1, "transparent pixels around each image")
+		out     = flag.String("out", "atlas", "path prefix for the pages and manifests")
+	)
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: atlas [-size 1024] [-padding 1] [-out atlas] <image dir>")
+		os.Exit(2)
+	}
+
+	runtime.LockOSThread()
+	if err := image.Install(); err != nil {
+		fail(err)
+	}
+	defer image.Uninstall()
+	// There's no display, so everything has to live in memory.
+	allegro.SetNewBitmapFlags(allegro.MEMORY_BITMAP)
+
+	if err := cache.LoadImages(flag.Arg(0)); err != nil {
+		if errs, ok := err.(cache.LoadErrors); ok {
+			for _, err := range errs {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}
+	atlases, err := cache.PackImages(cache.AtlasOptions{Width: *size, Height: *size, Padding: *padding})
+	if err != nil {
+		fail(err)
+	}
+	for i, atlas := range atlases {
+		name := fmt.Sprintf("%s-%d", *out, i)
+		if err := atlas.Save(name+".png", name+".json"); err != nil {
+			fail(err)
+		}
+		fmt.Printf("%s.png: %d images\n", name, len(atlas.Regions))
+	}
+	cache.ClearAll()
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,384 @@
+package allegory
+
+import (
+	"errors"
+)
+
+var (
+	ProcessTimedOut = errors.New("process timed out")
+)
+
+/* -- child -- */
+
+// child is a process that's run directly by another process
+// instead of being kicked off with RunProcess().
+type child struct {
+	proc    interface{}
+	running bool
+}
+
+// start() initializes the child.
+func (c *child) start() error {
+	if initFn := initFunc(c.proc); initFn != nil {
+		if err := initFn(); err != nil {
+			return err
+		}
+	}
+	c.running = true
+	return nil
+}
+
+// tick() ticks the child, returning false once it has finished. When the
+// child finishes without an error, its successor, if any, takes its place.
+func (c *child) tick() (bool, error) {
+	if !c.running {
+		return false, nil
+	}
+	tickFn := tickFunc(c.proc)
+	if tickFn == nil {
+		return true, nil
+	}
+	alive, err := tickFn()
+	if err != nil {
+		c.stop()
+		return false, err
+	}
+	if alive {
+		return true, nil
+	}
+	c.stop()
+	if proc, ok := c.proc.(Continuable); ok {
+		if next := proc.Next(); next != nil {
+			c.proc = next
+			if err := c.start(); err != nil {
+				return false, err
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// notify() passes a message along to the child.
+func (c *child) notify(msg interface{}) error {
+	if !c.running {
+		return nil
+	}
+	if err := deliver(c.proc, msg); err != nil {
+		c.stop()
+		return err
+	}
+	return nil
+}
+
+// stop() cleans up the child if it's still running.
+func (c *child) stop() {
+	if !c.running {
+		return
+	}
+	c.running = false
+	if cleanupFn := cleanupFunc(c.proc); cleanupFn != nil {
+		cleanupFn()
+	}
+}
+
+// startAll() starts each of the processes as children, stopping
+// any that were already started if one of them fails.
+func startAll(procs []interface{}) ([]*child, error) {
+	children := make([]*child, len(procs))
+	for i, proc := range procs {
+		children[i] = &child{proc: proc}
+		if err := children[i].start(); err != nil {
+			stopAll(children[:i])
+			return nil, err
+		}
+	}
+	return children, nil
+}
+
+// stopAll() stops each of the children.
+func stopAll(children []*child) {
+	for _, c := range children {
+		c.stop()
+	}
+}
+
+// notifyAll() passes a message along to each of the children,
+// stopping all of them if any returns an error.
+func notifyAll(children []*child, msg interface{}) error {
+	for _, c := range children {
+		if err := c.notify(msg); err != nil {
+			stopAll(children)
+			return err
+		}
+	}
+	return nil
+}
+
+/* -- SequenceProcess -- */
+
+// SequenceProcess is a process that runs each of its processes
+// in turn, starting the next one when the previous one finishes.
+// It finishes after the last one does, and fails as soon as any
+// of them fails.
+type SequenceProcess struct {
+	current *child
+	index   int
+
+	// Processes is the list of processes to run. They should
+	// not have been passed to RunProcess().
+	Processes []interface{}
+}
+
+// Sequence() returns a process that runs each of procs in turn.
+func Sequence(procs ...interface{}) *SequenceProcess {
+	return &SequenceProcess{Processes: procs}
+}
+
+func (p *SequenceProcess) init() error {
+	_, err := p.advance()
+	return err
+}
+
+// advance() starts the next process in the sequence, returning
+// false if there aren't any left.
+func (p *SequenceProcess) advance() (bool, error) {
+	p.current = nil
+	if p.index >= len(p.Processes) {
+		return false, nil
+	}
+	c := &child{proc: p.Processes[p.index]}
+	p.index++
+	if err := c.start(); err != nil {
+		return false, err
+	}
+	p.current = c
+	return true, nil
+}
+
+func (p *SequenceProcess) tick() (bool, error) {
+	if p.current == nil {
+		return false, nil
+	}
+	alive, err := p.current.tick()
+	if err != nil || alive {
+		return alive, err
+	}
+	return p.advance()
+}
+
+func (p *SequenceProcess) handleMessage(msg interface{}) error {
+	if p.current == nil {
+		return nil
+	}
+	return p.current.notify(msg)
+}
+
+func (p *SequenceProcess) cleanup() {
+	if p.current != nil {
+		p.current.stop()
+	}
+}
+
+/* -- ParallelProcess -- */
+
+// ParallelProcess is a process that runs all of its processes at
+// once. It finishes when all of them have finished, and fails as
+// soon as any of them fails.
+type ParallelProcess struct {
+	children []*child
+
+	// Processes is the list of processes to run. They should
+	// not have been passed to RunProcess().
+	Processes []interface{}
+}
+
+// Parallel() returns a process that runs all of procs at once.
+func Parallel(procs ...interface{}) *ParallelProcess {
+	return &ParallelProcess{Processes: procs}
+}
+
+func (p *ParallelProcess) init() (err error) {
+	p.children, err = startAll(p.Processes)
+	return
+}
+
+func (p *ParallelProcess) tick() (bool, error) {
+	running := false
+	for _, c := range p.children {
+		alive, err := c.tick()
+		if err != nil {
+			stopAll(p.children)
+			return false, err
+		}
+		running = running || alive
+	}
+	return running, nil
+}
+
+func (p *ParallelProcess) handleMessage(msg interface{}) error {
+	return notifyAll(p.children, msg)
+}
+
+func (p *ParallelProcess) cleanup() {
+	stopAll(p.children)
+}
+
+/* -- RaceProcess -- */
+
+// RaceProcess is a process that runs all of its processes at once.
+// It finishes as soon as one of them finishes, closing the rest,
+// and fails as soon as any of them fails.
+type RaceProcess struct {
+	children []*child
+
+	// Processes is the list of processes to run. They should
+	// not have been passed to RunProcess().
+	Processes []interface{}
+}
+
+// Race() returns a process that runs all of procs at once
+// until one of them finishes.
+func Race(procs ...interface{}) *RaceProcess {
+	return &RaceProcess{Processes: procs}
+}
+
+func (p *RaceProcess) init() (err error) {
+	p.children, err = startAll(p.Processes)
+	return
+}
+
+func (p *RaceProcess) tick() (bool, error) {
+	for _, c := range p.children {
+		alive, err := c.tick()
+		if err != nil || !alive {
+			stopAll(p.children)
+			return false, err
+		}
+	}
+	return len(p.children) > 0, nil
+}
+
+func (p *RaceProcess) handleMessage(msg interface{}) error {
+	return notifyAll(p.children, msg)
+}
+
+func (p *RaceProcess) cleanup() {
+	stopAll(p.children)
+}
+
+/* -- RepeatProcess -- */
+
+// RepeatProcess is a process that runs a new instance of
+// another process each time the previous one finishes.
+type RepeatProcess struct {
+	current *child
+	runs    int
+
+	// Times is the total number of times to run the process.
+	// Use RepeatForever to keep running it until closed.
+	Times int
+
+	// New is called to create each instance of the process.
+	New func() interface{}
+}
+
+// Repeat() returns a process that runs a process created
+// by f the given number of times.
+func Repeat(times int, f func() interface{}) *RepeatProcess {
+	return &RepeatProcess{Times: times, New: f}
+}
+
+func (p *RepeatProcess) init() error {
+	_, err := p.advance()
+	return err
+}
+
+// advance() starts the next run, returning false if
+// there aren't any left.
+func (p *RepeatProcess) advance() (bool, error) {
+	p.current = nil
+	if p.Times != RepeatForever && p.runs >= p.Times {
+		return false, nil
+	}
+	c := &child{proc: p.New()}
+	p.runs++
+	if err := c.start(); err != nil {
+		return false, err
+	}
+	p.current = c
+	return true, nil
+}
+
+func (p *RepeatProcess) tick() (bool, error) {
+	if p.current == nil {
+		return false, nil
+	}
+	alive, err := p.current.tick()
+	if err != nil || alive {
+		return alive, err
+	}
+	return p.advance()
+}
+
+func (p *RepeatProcess) handleMessage(msg interface{}) error {
+	if p.current == nil {
+		return nil
+	}
+	return p.current.notify(msg)
+}
+
+func (p *RepeatProcess) cleanup() {
+	if p.current != nil {
+		p.current.stop()
+	}
+}
+
+/* -- TimeoutProcess -- */
+
+// TimeoutProcess is a process that runs another process, failing
+// with ProcessTimedOut and closing it if it doesn't finish in time.
+type TimeoutProcess struct {
+	c     *child
+	timer uint
+
+	// Process is the process to run. It should not have been
+	// passed to RunProcess().
+	Process interface{}
+
+	// Limit is the number of ticks that the process has to finish.
+	// A value of 0 means no limit, so the process runs until it ends.
+	Limit uint
+}
+
+// Timeout() returns a process that gives proc limit ticks to
+// finish, or as long as it needs if limit is 0.
+func Timeout(limit uint, proc interface{}) *TimeoutProcess {
+	return &TimeoutProcess{Process: proc, Limit: limit}
+}
+
+func (p *TimeoutProcess) init() error {
+	p.c = &child{proc: p.Process}
+	return p.c.start()
+}
+
+func (p *TimeoutProcess) tick() (bool, error) {
+	alive, err := p.c.tick()
+	if err != nil || !alive {
+		return false, err
+	}
+	p.timer++
+	if p.Limit > 0 && p.timer >= p.Limit {
+		p.c.stop()
+		return false, ProcessTimedOut
+	}
+	return true, nil
+}
+
+func (p *TimeoutProcess) handleMessage(msg interface{}) error {
+	return p.c.notify(msg)
+}
+
+func (p *TimeoutProcess) cleanup() {
+	p.c.stop()
+}
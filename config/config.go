@@ -3,6 +3,8 @@ package config
 
 import (
 	"github.com/dradtke/go-allegro/allegro"
+	"strings"
+	"time"
 )
 
 // Default values.
@@ -15,6 +17,14 @@ var (
 	display_width  = 640
 	display_height = 480
 	display_flags  = allegro.WINDOWED
+
+	shutdown_timeout = 3 * time.Second
+
+	hot_reload          bool
+	hot_reload_interval = time.Second
+
+	asset_manifest string
+	strict_assets  bool
 )
 
 const CONSOLE_FILE = "build/console.txt"
@@ -75,6 +85,13 @@ func WindowTitle() string {
 	return title
 }
 
+// SetPackageRoot() sets the import path of the game's package. On
+// startup, if nothing has been mounted, the working directory is changed
+// to the package's directory in GOPATH.
+//
+// Deprecated: mount the game's data with allegory.MountDir() instead,
+// using vfs.ExecutableDir() or vfs.PackageDir() to find it, which
+// doesn't depend on the working directory.
 func SetPackageRoot(value string) {
 	pkg_root = value
 }
@@ -82,3 +99,66 @@ func SetPackageRoot(value string) {
 func PackageRoot() string {
 	return pkg_root
 }
+
+// ShutdownTimeout() returns how long processes are given to quit when
+// changing states or exiting before they're abandoned.
+func ShutdownTimeout() time.Duration {
+	return shutdown_timeout
+}
+
+func SetShutdownTimeout(value time.Duration) {
+	shutdown_timeout = value
+}
+
+// HotReload() returns whether or not files loaded through the cache
+// are checked for changes and reloaded while the game is running.
+func HotReload() bool {
+	return hot_reload
+}
+
+func SetHotReload(value bool) {
+	hot_reload = value
+}
+
+// HotReloadInterval() returns how often files are checked
+// for changes when hot reloading is enabled.
+func HotReloadInterval() time.Duration {
+	return hot_reload_interval
+}
+
+func SetHotReloadInterval(value time.Duration) {
+	hot_reload_interval = value
+}
+
+// AssetManifest() returns the path of the asset manifest that's checked
+// on startup, or an empty string if there isn't one.
+func AssetManifest() string {
+	return asset_manifest
+}
+
+func SetAssetManifest(value string) {
+	asset_manifest = value
+}
+
+// StrictAssets() returns whether or not the game refuses to start when
+// its asset manifest lists files that are missing or unreadable, rather
+// than logging them and carrying on.
+func StrictAssets() bool {
+	return strict_assets
+}
+
+func SetStrictAssets(value bool) {
+	strict_assets = value
+}
+
+// SplitList() splits a comma-separated config value, trimming
+// each item and leaving out empty ones.
+func SplitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,99 @@
+package allegory
+
+// CoverPolicy determines what happens to a process while its state
+// is covered by another one that was pushed on top of it.
+type CoverPolicy int
+
+const (
+	// PauseWhenCovered stops ticking the process until its state is
+	// uncovered. It's sent a Paused message when the state is covered,
+	// and a Resumed message when it's uncovered. Any other messages sent
+	// to a paused synchronous process are delivered once it resumes.
+	// This is the default.
+	PauseWhenCovered CoverPolicy = iota
+
+	// TickWhenCovered keeps ticking the process as usual.
+	TickWhenCovered
+
+	// QuitWhenCovered closes the process as soon as its state is covered.
+	QuitWhenCovered
+)
+
+// Messages delivered to processes that are paused and
+// resumed because of their CoverPolicy.
+type (
+	Paused  struct{}
+	Resumed struct{}
+)
+
+// SetCoverPolicy() changes what happens to the process while its state
+// is covered. It returns the process to allow chaining with RunProcess().
+// Processes can also choose their policy by implementing Coverable.
+func (p *Process) SetCoverPolicy(policy CoverPolicy) *Process {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.cover = policy
+	return p
+}
+
+// CoverPolicy() returns the process's cover policy.
+func (p *Process) CoverPolicy() CoverPolicy {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.cover
+}
+
+// Paused() returns true if the process is paused
+// because its state is covered.
+func (p *Process) Paused() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.paused
+}
+
+func (p *Process) setPaused(paused bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.paused = paused
+}
+
+// notice() sends a message to the process, delivering it immediately
+// if the process is synchronous. If the process is the one being
+// stepped, such as when it changes the state from its own tick, the
+// message is delivered as soon as its tick returns instead.
+func (p *Process) notice(msg interface{}) {
+	NotifyProcess(p, msg)
+	if p.async {
+		return
+	}
+	if p.stepping {
+		p.noticed = true
+		return
+	}
+	p.flush()
+}
+
+// coverProcesses() applies each of the state's
+// processes' policies when it gets covered.
+func coverProcesses(state *gameState) {
+	for _, p := range processesIn(state) {
+		switch p.CoverPolicy() {
+		case PauseWhenCovered:
+			p.setPaused(true)
+			p.notice(&Paused{})
+		case QuitWhenCovered:
+			p.notice(&quit{})
+		}
+	}
+}
+
+// uncoverProcesses() resumes each of the state's
+// paused processes when it gets uncovered.
+func uncoverProcesses(state *gameState) {
+	for _, p := range processesIn(state) {
+		if p.Paused() {
+			p.setPaused(false)
+			p.notice(&Resumed{})
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package allegory
+
+// CullPolicy determines how the engine treats actors that are
+// outside of the view. Only actors that implement Bounded and
+// have a non-empty size are ever culled.
+type CullPolicy struct {
+	// Hide causes the actor to not be rendered while it is
+	// outside of every camera's view.
+	Hide bool
+
+	// Sleep causes the actor to not be updated while it is more
+	// than SleepDistance away from every camera's view. It wakes up
+	// as soon as it comes back within range.
+	Sleep         bool
+	SleepDistance float32
+}
+
+// DefaultCulling is the policy used for actors when neither the actor
+// nor its layer has been given one. Culling is off by default, since an
+// actor's Bounds() don't always cover everything that it draws; turn it
+// on for layers or actors whose bounds are accurate, such as sprites.
+var DefaultCulling = CullPolicy{}
+
+// SetLayerCulling() sets the culling policy for every actor on
+// one of the current state's layers.
+func SetLayerCulling(layer uint, policy CullPolicy) {
+	cur := _state.Current()
+	if cur == nil {
+		return
+	}
+	_layerCulling[cur][layer] = policy
+}
+
+// SetActorCulling() sets an actor's culling policy, overriding the one
+// for its layer. Passing nil reverts the actor to its layer's policy.
+func SetActorCulling(actor interface{}, policy *CullPolicy) {
+	if policy == nil {
+		delete(_actorCulling, actor)
+	} else {
+		_actorCulling[actor] = *policy
+	}
+}
+
+// Asleep() returns true if the actor is currently too far
+// from the view to be updated.
+func Asleep(actor interface{}) bool {
+	return asleep(actor, cullViews())
+}
+
+// cullPolicy() returns the culling policy in effect for an actor.
+func cullPolicy(actor interface{}) CullPolicy {
+	if policy, ok := _actorCulling[actor]; ok {
+		return policy
+	}
+	if layer, ok := _actorLayer[actor]; ok {
+		if policy, ok := _state.LayerCulling()[layer]; ok {
+			return policy
+		}
+	}
+	return DefaultCulling
+}
+
+// cullViews() returns the areas of the world currently visible on screen.
+func cullViews() []Rect {
+	cameras := _state.Cameras()
+	if len(cameras) == 0 {
+		return []Rect{{0, 0, float32(_display.Width()), float32(_display.Height())}}
+	}
+	views := make([]Rect, len(cameras))
+	for i, cam := range cameras {
+		views[i] = cam.View()
+	}
+	return views
+}
+
+// hidden() returns true if the actor shouldn't be rendered in view.
+func hidden(actor interface{}, view Rect) bool {
+	return cullPolicy(actor).Hide && !inView(actor, view)
+}
+
+// asleep() returns true if the actor shouldn't be updated
+// because it's too far from all of the views.
+func asleep(actor interface{}, views []Rect) bool {
+	policy := cullPolicy(actor)
+	if !policy.Sleep {
+		return false
+	}
+	d := policy.SleepDistance
+	for _, view := range views {
+		if inView(actor, Rect{view.X - d, view.Y - d, view.W + 2*d, view.H + 2*d}) {
+			return false
+		}
+	}
+	return true
+}
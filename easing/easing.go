@@ -0,0 +1,123 @@
+// Package easing provides curves for controlling the rate of change
+// of tweened values.
+//
+// Each curve takes the fraction of the tween's duration that has
+// elapsed, from 0 to 1, and returns the fraction of the distance
+// that the value should have travelled. Curves named In start slowly,
+// ones named Out finish slowly, and ones named InOut do both. Some
+// curves, such as Back and Elastic, overshoot the range on purpose.
+package easing
+
+import (
+	"math"
+)
+
+// Func is an easing curve.
+type Func func(t float32) float32
+
+// Linear() changes the value at a constant rate.
+func Linear(t float32) float32 {
+	return t
+}
+
+func QuadIn(t float32) float32 {
+	return t * t
+}
+
+func QuadOut(t float32) float32 {
+	return t * (2 - t)
+}
+
+func QuadInOut(t float32) float32 {
+	if t < 0.5 {
+		return 2 * t * t
+	}
+	return -1 + (4-2*t)*t
+}
+
+func CubicIn(t float32) float32 {
+	return t * t * t
+}
+
+func CubicOut(t float32) float32 {
+	t--
+	return t*t*t + 1
+}
+
+func CubicInOut(t float32) float32 {
+	if t < 0.5 {
+		return 4 * t * t * t
+	}
+	t = 2*t - 2
+	return 0.5*t*t*t + 1
+}
+
+// back is how far the Back curves overshoot.
+const back = 1.70158
+
+func BackIn(t float32) float32 {
+	return t * t * ((back+1)*t - back)
+}
+
+func BackOut(t float32) float32 {
+	t--
+	return t*t*((back+1)*t+back) + 1
+}
+
+func BackInOut(t float32) float32 {
+	const s = back * 1.525
+	if t < 0.5 {
+		t *= 2
+		return 0.5 * (t * t * ((s+1)*t - s))
+	}
+	t = 2*t - 2
+	return 0.5 * (t*t*((s+1)*t+s) + 2)
+}
+
+func ElasticIn(t float32) float32 {
+	if t == 0 || t == 1 {
+		return t
+	}
+	return -float32(math.Pow(2, float64(10*(t-1))) * math.Sin(float64(t-1.075)*(2*math.Pi)/0.3))
+}
+
+func ElasticOut(t float32) float32 {
+	if t == 0 || t == 1 {
+		return t
+	}
+	return float32(math.Pow(2, float64(-10*t))*math.Sin(float64(t-0.075)*(2*math.Pi)/0.3)) + 1
+}
+
+func ElasticInOut(t float32) float32 {
+	if t < 0.5 {
+		return 0.5 * ElasticIn(2*t)
+	}
+	return 0.5*ElasticOut(2*t-1) + 0.5
+}
+
+func BounceIn(t float32) float32 {
+	return 1 - BounceOut(1-t)
+}
+
+func BounceOut(t float32) float32 {
+	switch {
+	case t < 1/2.75:
+		return 7.5625 * t * t
+	case t < 2/2.75:
+		t -= 1.5 / 2.75
+		return 7.5625*t*t + 0.75
+	case t < 2.5/2.75:
+		t -= 2.25 / 2.75
+		return 7.5625*t*t + 0.9375
+	default:
+		t -= 2.625 / 2.75
+		return 7.5625*t*t + 0.984375
+	}
+}
+
+func BounceInOut(t float32) float32 {
+	if t < 0.5 {
+		return 0.5 * BounceIn(2*t)
+	}
+	return 0.5*BounceOut(2*t-1) + 0.5
+}
@@ -3,14 +3,12 @@ package actors
 import (
 	"github.com/dradtke/allegory"
 	"github.com/dradtke/allegory/bus"
-	"github.com/dradtke/allegory/cache"
 	"github.com/dradtke/allegory/example/signals"
 	"github.com/dradtke/go-allegro/allegro"
-	"strconv"
 )
 
 type Hero struct {
-	allegory.Actor
+	allegory.Sprite
 	Gravity   float32
 	GroundY   float32
 	Jumpspeed float32
@@ -26,7 +24,7 @@ func (h *Hero) Standing(dir int8) *heroStanding {
 }
 
 func (h *Hero) Walking(dir int8) *heroWalking {
-	return &heroWalking{h, dir, nil}
+	return &heroWalking{h, dir}
 }
 
 /* -- Standing -- */
@@ -36,8 +34,8 @@ type heroStanding struct {
 	dir  int8
 }
 
-func (h *heroStanding) Render(delta float32) {
-	cache.Image("standing.png").Draw(h.hero.X, h.hero.Y, dirToFlags(h.dir))
+func (h *heroStanding) Init() {
+	h.hero.Flip = dirToFlags(h.dir)
 }
 
 func (h *heroStanding) HandleEvent(event interface{}) interface{} {
@@ -70,35 +68,17 @@ func (h *heroStanding) HandleEvent(event interface{}) interface{} {
 /* -- Walking -- */
 
 type heroWalking struct {
-	hero      *Hero
-	dir       int8
-	animation *allegory.AnimationProcess
+	hero *Hero
+	dir  int8
 }
 
 func (h *heroWalking) Init() {
-	images := make([]*allegro.Bitmap, 0)
-	var (
-		err   error
-		frame *allegro.Bitmap
-	)
-	for i := 1; err == nil; i++ {
-		if frame, err = cache.FindImage("walking-" + strconv.Itoa(i) + ".png"); err == nil {
-			images = append(images, frame)
-		}
-	}
-	h.animation = &allegory.AnimationProcess{Repeat: true, Step: 6, Frames: images}
-	allegory.RunProcess(h.animation)
-}
-
-func (h *heroWalking) Render(delta float32) {
-	x, y := h.hero.CalculatePos(delta)
-	h.animation.CurrentFrame().Draw(x, y, dirToFlags(h.dir))
+	h.hero.Flip = dirToFlags(h.dir)
 }
 
 func (h *heroWalking) Update() interface{} {
 	left, right := allegory.KeyDown(allegro.KEY_LEFT), allegory.KeyDown(allegro.KEY_RIGHT)
 	if left == right {
-		allegory.Close(h.animation)
 		return h.hero.Standing(h.dir)
 	}
 	if h.dir > 0 && left {
@@ -106,6 +86,7 @@ func (h *heroWalking) Update() interface{} {
 	} else if h.dir < 0 && right {
 		h.dir = 1
 	}
+	h.hero.Flip = dirToFlags(h.dir)
 	h.hero.Move(float32(h.hero.Walkspeed)*float32(h.dir), 0)
 	return nil
 }
@@ -130,6 +111,10 @@ type heroJumping struct {
 	jumpspeed float32
 }
 
+func (h *heroJumping) Init() {
+	h.hero.Flip = dirToFlags(h.dir)
+}
+
 func (h *heroJumping) Update() interface{} {
 	h.hero.Move(float32(h.dir)*float32(h.velocity), h.jumpspeed)
 	h.jumpspeed += h.hero.Gravity
@@ -145,7 +130,3 @@ func (h *heroJumping) Update() interface{} {
 	}
 	return nil
 }
-
-func (h *heroJumping) Render(delta float32) {
-	cache.Image("standing.png").Draw(h.hero.X, h.hero.Y, dirToFlags(h.dir))
-}
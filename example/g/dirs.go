@@ -4,4 +4,5 @@ const (
 	DATA_DIR = "data"
 	IMG_DIR = DATA_DIR + "/images"
 	GAME_CONFIG = DATA_DIR + "/game.cfg"
+	ASSET_MANIFEST = DATA_DIR + "/assets.json"
 )
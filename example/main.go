@@ -2,13 +2,55 @@ package main
 
 import (
 	"github.com/dradtke/allegory"
+	"github.com/dradtke/allegory/cache"
+	"github.com/dradtke/allegory/config"
+	"github.com/dradtke/allegory/example/g"
 	"github.com/dradtke/allegory/example/playing"
 	"github.com/dradtke/allegory/example/playing/paused"
+	"github.com/dradtke/allegory/vfs"
+	"os"
+	"path/filepath"
 )
 
 func main() {
 	playing.Register()
 	paused.Register()
 
-	allegory.Run("playing")
+	// Anything in a folder or zip under mods/ overrides the base data.
+	base := baseDir()
+	if err := allegory.MountDir("base", base, vfs.BasePriority); err != nil {
+		allegory.Fatal(err)
+	}
+	if _, err := allegory.Mounts().MountEach(filepath.Join(base, "mods"), vfs.ModPriority); err != nil {
+		allegory.Fatal(err)
+	}
+	allegory.Conflicts()
+
+	// Refuse to start if anything listed in the manifest is missing.
+	config.SetAssetManifest(g.ASSET_MANIFEST)
+	config.SetStrictAssets(true)
+
+	// Pick up changes to images and game.cfg without restarting.
+	config.SetHotReload(true)
+
+	loader := cache.NewLoader()
+	loader.AddImages(g.IMG_DIR)
+	loader.Atlas = &cache.AtlasOptions{Padding: 1}
+	allegory.DefLoadingState("loading", loader, "playing")
+
+	allegory.Run("loading")
+}
+
+// baseDir() finds the directory that the game's data is in: next to the
+// executable once it's been built, or in GOPATH when run with "go run".
+func baseDir() string {
+	if dir, err := vfs.ExecutableDir("."); err == nil {
+		if _, err := os.Stat(filepath.Join(dir, g.DATA_DIR)); err == nil {
+			return dir
+		}
+	}
+	if dir, err := vfs.PackageDir("github.com/dradtke/allegory/example"); err == nil {
+		return dir
+	}
+	return "."
 }
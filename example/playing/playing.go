@@ -11,14 +11,16 @@ import (
 )
 
 var (
-	_hero *actors.Hero
+	_hero      *actors.Hero
+	_footsteps *bus.Listener
 )
 
 func Register() {
 	allegory.DefState("playing").
 		Init(Init).
 		Update(Update).
-		HandleEvent(HandleEvent)
+		HandleEvent(HandleEvent).
+		Cleanup(Cleanup)
 }
 
 func Init() {
@@ -27,24 +29,39 @@ func Init() {
 		cfg *allegro.Config
 	)
 
-	err = cache.LoadImages(g.IMG_DIR)
-	if err != nil {
+	if err = cache.LoadConfig(g.GAME_CONFIG, "game"); err != nil {
 		allegory.Fatal(err)
 	}
+	cfg = cache.Config("game")
 
-	cfg, err = allegro.LoadConfig(g.GAME_CONFIG)
-	if err != nil {
+	if err = cache.LoadClips(cfg, "standing", "walking"); err != nil {
 		allegory.Fatal(err)
 	}
 
 	_hero = new(actors.Hero)
 	_hero.X, _hero.Y = 200, 200
-	allegory.ReadConfig(cfg, "Hero", _hero)
+	if err = allegory.BindConfig("game", "Hero", _hero); err != nil {
+		allegory.Fatal(err)
+	}
 	allegory.AddActor(1, _hero, _hero.Standing(1))
 
+	if _hero.Animator, err = allegory.LoadAnimator(cfg, "hero"); err != nil {
+		allegory.Fatal(err)
+	}
+	_hero.Animator.Actor = _hero
+	allegory.RunProcess(_hero.Animator)
+
 	bus.AddListener(signals.HERO_LANDED, func() {
 		allegory.Debug("The hero has landed!")
 	})
+	_footsteps, err = bus.Listen(bus.AnimationFrameEvent, func(anim interface{}, event string) {
+		if event == "footstep" {
+			allegory.Debug("Step.")
+		}
+	})
+	if err != nil {
+		allegory.Fatal(err)
+	}
 }
 
 func HandleEvent(event interface{}) bool {
@@ -68,3 +85,8 @@ func HandleEvent(event interface{}) bool {
 func Update() {
 	// TODO: write updates here
 }
+
+func Cleanup() {
+	_footsteps.Remove()
+	allegory.UnbindConfig(_hero)
+}
@@ -0,0 +1,78 @@
+package allegory
+
+import (
+	"github.com/dradtke/allegory/bus"
+	"github.com/dradtke/allegory/cache"
+	"github.com/dradtke/allegory/config"
+	"time"
+)
+
+// configBinding is a config section that's read into
+// a value whenever its config is reloaded.
+type configBinding struct {
+	section string
+	dest    interface{}
+}
+
+var (
+	_configBindings  = make(map[string][]configBinding)
+	_lastReloadCheck time.Time
+)
+
+// BindConfig() reads a section of the config stored in the cache under
+// key into dest using ReadConfig(), and reads it again whenever the
+// config is hot reloaded, so that values such as an actor's speed can
+// be tweaked while the game is running.
+func BindConfig(key, section string, dest interface{}) error {
+	cfg, err := cache.FindConfig(key)
+	if err != nil {
+		return err
+	}
+	ReadConfig(cfg, section, dest)
+	_configBindings[key] = append(_configBindings[key], configBinding{section, dest})
+	return nil
+}
+
+// UnbindConfig() stops reloading config values into dest.
+func UnbindConfig(dest interface{}) {
+	for key, bindings := range _configBindings {
+		kept := bindings[:0]
+		for _, b := range bindings {
+			if b.dest != dest {
+				kept = append(kept, b)
+			}
+		}
+		if len(kept) == 0 {
+			delete(_configBindings, key)
+		} else {
+			_configBindings[key] = kept
+		}
+	}
+}
+
+// checkForReloads() reloads any files in the cache that have changed, if
+// hot reloading is enabled and it's been long enough since the last check.
+func checkForReloads(now time.Time) {
+	if !config.HotReload() || now.Sub(_lastReloadCheck) < config.HotReloadInterval() {
+		return
+	}
+	_lastReloadCheck = now
+
+	reloaded, err := cache.ReloadModified()
+	if errs, ok := err.(cache.LoadErrors); ok {
+		for _, err := range errs {
+			Error(err)
+		}
+	}
+	for _, info := range reloaded {
+		Infof("reloaded %s %s", info.Kind, info.Key)
+		if info.Kind == cache.ConfigResource {
+			if cfg, err := cache.FindConfig(info.Key); err == nil {
+				for _, b := range _configBindings[info.Key] {
+					ReadConfig(cfg, b.section, b.dest)
+				}
+			}
+		}
+		bus.Signal(bus.ResourceReloadedEvent, info.Kind, info.Key)
+	}
+}
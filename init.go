@@ -2,32 +2,29 @@ package allegory
 
 import (
 	"container/list"
+	"github.com/dradtke/allegory/cache"
 	"github.com/dradtke/allegory/config"
+	"github.com/dradtke/allegory/vfs"
 	"github.com/dradtke/go-allegro/allegro"
 	"github.com/dradtke/go-allegro/allegro/dialog"
 	"github.com/dradtke/go-allegro/allegro/font"
 	"github.com/dradtke/go-allegro/allegro/image"
 	"github.com/dradtke/go-allegro/allegro/primitives"
 	"os"
-	"path/filepath"
 	"runtime"
 )
 
 // Init() initializes the game by creating the event queue, installing
-// input systems, creating the display, and starting the FPS timer. It also
-// changes the working directory to the package root relative to GOPATH,
-// if one was specified.
+// input systems, creating the display, and starting the FPS timer. If a
+// package root was specified and nothing has been mounted, it also changes
+// the working directory to the package's directory in GOPATH.
 func initialize(state *gameState) {
 	runtime.LockOSThread()
 	var err error
 
-	if pkg_root := config.PackageRoot(); pkg_root != "" {
-		for _, dir := range filepath.SplitList(os.Getenv("GOPATH")) {
-			p := filepath.Join(dir, "src", pkg_root)
-			if _, err := os.Stat(p); !os.IsNotExist(err) {
-				os.Chdir(p)
-				break
-			}
+	if pkg_root := config.PackageRoot(); pkg_root != "" && cache.FS() == nil {
+		if dir, err := vfs.PackageDir(pkg_root); err == nil {
+			os.Chdir(dir)
 		}
 	}
 
@@ -53,6 +50,9 @@ func initialize(state *gameState) {
 	font.Install()
 	_atexit = append(_atexit, font.Uninstall)
 
+	// Asset Manifest
+	checkAssets()
+
 	// Event Queue
 	if _eventQueue, err = allegro.CreateEventQueue(); err != nil {
 		Fatal(err)
@@ -79,7 +79,7 @@ func initialize(state *gameState) {
 	if icons := config.WindowIcons(); icons != nil {
 		_displayIcons = make([]*allegro.Bitmap, 0)
 		for _, icon := range icons {
-			bmp, err := allegro.LoadBitmap(icon)
+			bmp, err := cache.OpenImage(icon)
 			if err != nil {
 				Error(err)
 				continue
@@ -99,12 +99,20 @@ func initialize(state *gameState) {
 	_eventQueue.Register(_fpsTimer)
 	_fpsTimer.Start()
 
+	// Main-thread Tasks
+	_taskSource = allegro.NewUserEventSource()
+	_eventQueue.RegisterEventSource(_taskSource)
+
 	_state = stateStack{list.New()}
-	_processes = make(map[*gameState][]interface{})
+	_processes = make(map[*gameState][]*Process)
 	_actors = make(map[*gameState][]interface{})
 	_actorLayers = make(map[*gameState]map[uint][]interface{})
 	_actorStates = make(map[interface{}]interface{})
-	_messengers = make(map[interface{}]chan interface{})
+	_cameras = make(map[*gameState][]*Camera)
+	_actorLayer = make(map[interface{}]uint)
+	_actorCulling = make(map[interface{}]CullPolicy)
+	_layerCulling = make(map[*gameState]map[uint]CullPolicy)
+	_handles = make(map[interface{}]*Process)
 	_pressedKeys = make(map[allegro.KeyCode]bool)
 }
 
@@ -114,6 +122,9 @@ func cleanup() {
 	if _fpsTimer != nil {
 		_fpsTimer.Destroy()
 	}
+	if _taskSource != nil {
+		_taskSource.Destroy()
+	}
 	if _display != nil {
 		_display.Destroy()
 	}
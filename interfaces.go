@@ -35,12 +35,36 @@ type Renderable interface {
 	Render(delta float32)
 }
 
+// Bounded is an interface for values that occupy an area of the world.
+// This includes actors, which are skipped during rendering when their
+// bounds fall outside of the view.
+type Bounded interface {
+	Bounds() Rect
+}
+
+// Private interface for actors that embed Actor, allowing the engine
+// to record their transform at the start of each step.
+type privatelySnapshottable interface {
+	snapshot()
+}
+
+// Animated is an interface for actor states that name the animation
+// state they correspond to, for use in an Animator's transitions.
+type Animated interface {
+	AnimationState() string
+}
+
 // Cleanupable is an interface for values that support end-of-life cleanup. This
 // includes game states and actors.
 type Cleanupable interface {
 	Cleanup()
 }
 
+// Private variant of Cleanupable for internal process definitions.
+type privatelyCleanupable interface {
+	cleanup()
+}
+
 // EventHandler is an interface for values that can receive Allegro events.
 // This includes game states.
 type EventHandler interface {
@@ -65,6 +89,14 @@ type privatelyMessagable interface {
 	handleMessage(msg interface{}) error
 }
 
+// Answerer is an interface for processes that can reply to messages
+// sent with Ask(). Unlike errors returned from HandleMessage(), errors
+// returned from Answer() are passed back to the sender as the reply,
+// and don't cause the process to fail.
+type Answerer interface {
+	Answer(msg interface{}) (interface{}, error)
+}
+
 // Tickable is an interface for processes that need to do something
 // on each frame.
 type Tickable interface {
@@ -81,3 +113,9 @@ type privatelyTickable interface {
 type Continuable interface {
 	Next() interface{}
 }
+
+// Coverable is an interface for processes that choose what happens
+// to them while their state is covered by another one.
+type Coverable interface {
+	CoverPolicy() CoverPolicy
+}
@@ -0,0 +1,57 @@
+package allegory
+
+import (
+	"github.com/dradtke/allegory/cache"
+	"github.com/dradtke/allegory/config"
+	"github.com/dradtke/go-allegro/allegro"
+	"github.com/dradtke/go-allegro/allegro/primitives"
+)
+
+// DefLoadingState() defines a loading screen. When entered, it starts the
+// loader and polls it each update, drawing a progress bar in the meantime.
+// Once everything has loaded, any errors are logged and the next state is
+// pushed on top of it; it's only pushed once, so popping back to the
+// loading screen won't push it again.
+//
+// Like any other state, its Render() function can be replaced to draw
+// a custom loading screen, using the loader's Progress().
+func DefLoadingState(id StateID, loader *cache.Loader, next StateID) *gameState {
+	pushed := false
+	return DefState(id).
+		Init(func() {
+			loader.Start()
+		}).
+		Update(func() {
+			if pushed || !loader.Poll() {
+				return
+			}
+			if errs, ok := loader.Err().(cache.LoadErrors); ok {
+				for _, err := range errs {
+					Error(err)
+				}
+			}
+			pushed = true
+			PushState(next)
+		}).
+		Render(func(delta float32) {
+			renderProgress(loader.Progress())
+		})
+}
+
+// renderProgress() draws a progress bar across the middle of the display.
+func renderProgress(progress float32) {
+	w, h := config.DisplaySize()
+	var (
+		left, right = float32(w) * 0.2, float32(w) * 0.8
+		top, bottom = float32(h)/2 - 8, float32(h)/2 + 8
+		white       = allegro.MapRGB(255, 255, 255)
+	)
+	primitives.DrawFilledRectangle(
+		primitives.Point{X: left, Y: top},
+		primitives.Point{X: left + (right-left)*progress, Y: bottom},
+		white)
+	primitives.DrawRectangle(
+		primitives.Point{X: left, Y: top},
+		primitives.Point{X: right, Y: bottom},
+		white, 2)
+}
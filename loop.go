@@ -82,6 +82,12 @@ func loop() {
 				ticking, handled = true, true
 			}
 
+		case allegro.UserEvent:
+			if e.Source() == _taskSource {
+				runTasks()
+				handled = true
+			}
+
 		case allegro.DisplayCloseEvent:
 			running, handled = false, true
 
@@ -101,9 +107,16 @@ func loop() {
 			elapsed = now.Sub(lastUpdate)
 			lastUpdate = now
 			lag += elapsed
+			checkForReloads(now)
 			for lag >= step {
-				NotifyAllProcesses(&tick{})
+				snapshotActors()
+				runSchedule()
+				tickProcesses()
+				views := cullViews()
 				for _, actor := range _state.Actors() {
+					if asleep(actor, views) {
+						continue
+					}
 					var updated bool
 					if state, ok := _actorStates[actor]; ok {
 						if state, ok := state.(UpdateableStatefully); ok {
@@ -134,27 +147,7 @@ func loop() {
 			_state.Render(delta)
 
 			//allegro.HoldBitmapDrawing(true) // ???: why does this kill it?
-			actorLayers := _state.ActorLayers()
-			for i := uint(0); i <= _highestLayer; i++ {
-				layer, ok := actorLayers[i]
-				if !ok {
-					continue
-				}
-				for _, actor := range layer {
-					var rendered bool
-					if state, ok := _actorStates[actor]; ok {
-						if state, ok := state.(Renderable); ok {
-							state.Render(delta)
-							rendered = true
-						}
-					}
-					if !rendered {
-						if actor, ok := actor.(Renderable); ok {
-							actor.Render(delta)
-						}
-					}
-				}
-			}
+			renderActors(delta)
 			//allegro.HoldBitmapDrawing(false)
 			allegro.FlipDisplay()
 
@@ -165,19 +158,15 @@ func loop() {
 	allegro.ClearToColor(config.BlankColor())
 	allegro.FlipDisplay()
 
-	// Tell all processes to quit immediately, then wait
-	// for them to finish before exiting.
+	// Popping each state tells its processes to quit, then waits for
+	// them to finish before exiting, all within a single timeout.
+	deadline := time.Now().Add(config.ShutdownTimeout())
+	abandoned := 0
 	for !_state.Empty() {
-		cur := _state.Current()
-		if cur == nil {
-			_state.Pop()
-		} else {
-			NotifyAllProcesses(&quit{})
-			for len(_processes[cur]) > 0 {
-				runtime.Gosched()
-			}
-			_state.Pop()
-			delete(_processes, cur)
-		}
+		_, stuck := _state.popWithin(time.Until(deadline))
+		abandoned += len(stuck)
+	}
+	if abandoned > 0 {
+		Errorf("%d processes were abandoned while exiting", abandoned)
 	}
 }
@@ -0,0 +1,230 @@
+package allegory
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var (
+	AskTimedOut = errors.New("timed out waiting for a reply")
+)
+
+// request is the message that's actually sent to a process by Ask().
+type request struct {
+	msg   interface{}
+	reply chan response
+}
+
+type response struct {
+	value interface{}
+	err   error
+}
+
+// respond() replies to the request. Only the first reply is kept.
+func (r *request) respond(value interface{}, err error) {
+	select {
+	case r.reply <- response{value, err}:
+	default:
+	}
+}
+
+// Ask() sends a message to a process and waits for its reply. If the
+// process is an Answerer, the reply is whatever Answer() returns;
+// otherwise the message goes to HandleMessage() and the reply is
+// empty once it's been handled. An error is returned if the process
+// has ended or doesn't reply before the timeout.
+//
+// Messages for synchronous processes are only delivered while the main
+// loop is running, so asking one from anything that blocks the main
+// thread, such as a synchronous process or a script, will always time
+// out. Use Ask() from a goroutine or an asynchronous process instead,
+// or ScriptContext.Ask() from a script.
+func Ask(proc interface{}, msg interface{}, timeout time.Duration) (interface{}, error) {
+	p := findProcess(proc)
+	if p == nil {
+		return nil, NoSuchProcess
+	}
+	r := &request{msg, make(chan response, 1)}
+	if err := NotifyProcess(p, r); err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-r.reply:
+		return resp.value, resp.err
+	case <-p.Done():
+		select {
+		case resp := <-r.reply:
+			return resp.value, resp.err
+		default:
+			return nil, p.endedError()
+		}
+	case <-time.After(timeout):
+		return nil, AskTimedOut
+	}
+}
+
+// deliver() passes a message to a process's handler, answering it
+// if it was sent with Ask(). The returned error, if any, should
+// cause the process to fail.
+func deliver(proc interface{}, msg interface{}) error {
+	r, ok := msg.(*request)
+	if !ok {
+		if handleMessageFn := messageFunc(proc); handleMessageFn != nil {
+			return handleMessageFn(msg)
+		}
+		return nil
+	}
+	if answerFn := answerFunc(proc); answerFn != nil && answers(proc, r.msg) {
+		r.respond(answerFn(r.msg))
+		return nil
+	}
+	var err error
+	if handleMessageFn := messageFunc(proc); handleMessageFn != nil {
+		err = handleMessageFn(r.msg)
+	}
+	r.respond(nil, err)
+	return err
+}
+
+// answerFunc() returns a process's Answer() method, if it has one.
+func answerFunc(proc interface{}) func(msg interface{}) (interface{}, error) {
+	p, ok := proc.(Answerer)
+	if !ok {
+		return nil
+	}
+	return func(msg interface{}) (value interface{}, err error) {
+		defer recoverError(&err)
+		return p.Answer(msg)
+	}
+}
+
+// answers() returns false if the process's Answer() comes from an
+// embedded Mailbox without a handler for the message, so that it
+// can be given to the process's own HandleMessage() instead.
+func answers(proc interface{}, msg interface{}) bool {
+	if m, ok := proc.(mailboxer); ok {
+		_, found := m.mailbox().handler(msg)
+		return found
+	}
+	return true
+}
+
+/* -- Mailbox -- */
+
+// Mailbox dispatches messages to handlers based on their type, so that
+// processes don't need to switch on it themselves. Embed it in a process
+// and register handlers in the process's Init() method:
+//
+//	type Guard struct {
+//	    allegory.Mailbox
+//	    alert bool
+//	}
+//
+//	func (g *Guard) Init() error {
+//	    g.Handle(func(msg *Noise) error {
+//	        g.alert = true
+//	        return nil
+//	    })
+//	    g.Handle(func(msg *IsAlert) (interface{}, error) {
+//	        return g.alert, nil
+//	    })
+//	    return nil
+//	}
+//
+// Handlers with the second signature can reply to messages sent with
+// Ask(). Messages without a matching handler are ignored.
+//
+// Embedding a Mailbox makes the process an Answerer, since Answer() is
+// promoted along with HandleMessage(). A process that embeds one can
+// still define its own HandleMessage() to handle the rest of its
+// messages; it gets every message, including those sent with Ask(),
+// that none of the mailbox's handlers accept.
+type Mailbox struct {
+	handlers map[reflect.Type]reflect.Value
+	order    []reflect.Type
+}
+
+// mailboxer is implemented by processes that embed a Mailbox.
+type mailboxer interface {
+	mailbox() *Mailbox
+}
+
+func (m *Mailbox) mailbox() *Mailbox {
+	return m
+}
+
+var (
+	_errorType     = reflect.TypeOf((*error)(nil)).Elem()
+	_interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// Handle() registers a handler for messages of the type accepted by f,
+// which must be either func(T) error or func(T) (interface{}, error).
+// If T is an interface, f handles any message that implements it
+// which doesn't have a handler of its own.
+func (m *Mailbox) Handle(f interface{}) {
+	v := reflect.ValueOf(f)
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 1 || !validReply(t) {
+		panic(fmt.Sprintf("invalid mailbox handler type %s", t))
+	}
+	if m.handlers == nil {
+		m.handlers = make(map[reflect.Type]reflect.Value)
+	}
+	in := t.In(0)
+	if _, ok := m.handlers[in]; !ok {
+		m.order = append(m.order, in)
+	}
+	m.handlers[in] = v
+}
+
+// validReply() returns true if the function type's
+// results are valid for a mailbox handler.
+func validReply(t reflect.Type) bool {
+	switch t.NumOut() {
+	case 1:
+		return t.Out(0) == _errorType
+	case 2:
+		return t.Out(0) == _interfaceType && t.Out(1) == _errorType
+	}
+	return false
+}
+
+// HandleMessage() dispatches a message to its handler.
+func (m *Mailbox) HandleMessage(msg interface{}) error {
+	_, err := m.Answer(msg)
+	return err
+}
+
+// Answer() dispatches a message to its handler, returning its reply.
+func (m *Mailbox) Answer(msg interface{}) (interface{}, error) {
+	f, ok := m.handler(msg)
+	if !ok {
+		return nil, nil
+	}
+	out := f.Call([]reflect.Value{reflect.ValueOf(msg)})
+	err, _ := out[len(out)-1].Interface().(error)
+	if len(out) == 1 {
+		return nil, err
+	}
+	return out[0].Interface(), err
+}
+
+// handler() returns the handler for a message, if there is one.
+func (m *Mailbox) handler(msg interface{}) (reflect.Value, bool) {
+	if msg == nil {
+		return reflect.Value{}, false
+	}
+	t := reflect.TypeOf(msg)
+	if f, ok := m.handlers[t]; ok {
+		return f, true
+	}
+	for _, in := range m.order {
+		if in.Kind() == reflect.Interface && t.Implements(in) {
+			return m.handlers[in], true
+		}
+	}
+	return reflect.Value{}, false
+}
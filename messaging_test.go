@@ -0,0 +1,51 @@
+package allegory
+
+import (
+	"testing"
+)
+
+type ping struct{}
+type status struct{}
+
+// guard answers pings with its mailbox, and everything else itself.
+type guard struct {
+	Mailbox
+	handled []interface{}
+}
+
+func (g *guard) HandleMessage(msg interface{}) error {
+	g.handled = append(g.handled, msg)
+	return nil
+}
+
+// ask() delivers a message as if it was sent with Ask(), and returns the reply.
+func ask(proc interface{}, msg interface{}) (interface{}, error) {
+	r := &request{msg, make(chan response, 1)}
+	deliver(proc, r)
+	resp := <-r.reply
+	return resp.value, resp.err
+}
+
+func TestMailboxFallsBackToHandleMessage(t *testing.T) {
+	g := new(guard)
+	g.Handle(func(msg *ping) (interface{}, error) {
+		return "pong", nil
+	})
+
+	if reply, err := ask(g, &ping{}); reply != "pong" || err != nil {
+		t.Errorf("ping reply = %v, %v; want pong, nil", reply, err)
+	}
+	if len(g.handled) != 0 {
+		t.Errorf("HandleMessage got %d messages, want 0", len(g.handled))
+	}
+
+	if _, err := ask(g, &status{}); err != nil {
+		t.Errorf("status error = %v, want nil", err)
+	}
+	if err := deliver(g, &status{}); err != nil {
+		t.Errorf("deliver error = %v, want nil", err)
+	}
+	if len(g.handled) != 2 {
+		t.Errorf("HandleMessage got %d messages, want 2", len(g.handled))
+	}
+}
@@ -18,6 +18,8 @@ func KeyDown(keyCode allegro.KeyCode) bool {
 // After() takes a list of functions and kicks each one off in its own goroutine,
 // then calls the callback once they've all finished. Everything is run
 // in a separate goroutine, so After() returns almost immediately.
+// Use RunOnMain() from within the callback for anything that
+// needs to happen on the main thread.
 func After(routines []func(), callback func()) {
 	var wg sync.WaitGroup
 	wg.Add(len(routines))
@@ -0,0 +1,58 @@
+package allegory
+
+import (
+	"github.com/dradtke/allegory/cache"
+	"github.com/dradtke/allegory/vfs"
+	"io/fs"
+)
+
+// _vfs is the game's virtual filesystem, created on first use.
+var _vfs *vfs.FS
+
+// Mounts() returns the game's virtual filesystem. The first time it's
+// called, the cache is pointed at it with cache.SetFS(), so every image,
+// font, sample and config is read from whichever mount has the file with
+// the highest priority. For example, to let mods override the base data:
+//
+//	allegory.MountDir("base", "data", vfs.BasePriority)
+//	allegory.MountDir("dlc", "dlc", vfs.DLCPriority)
+//	allegory.Mounts().MountEach("mods", vfs.ModPriority)
+//
+// Mounting should be done before anything is loaded.
+func Mounts() *vfs.FS {
+	if _vfs == nil {
+		_vfs = vfs.New()
+		cache.SetFS(_vfs)
+		_atexit = append(_atexit, func() { _vfs.Close() })
+	}
+	return _vfs
+}
+
+// Mount() mounts fsys into the game's virtual filesystem.
+func Mount(name string, fsys fs.FS, priority int) error {
+	return Mounts().Mount(name, fsys, priority)
+}
+
+// MountDir() mounts a directory into the game's virtual filesystem.
+func MountDir(name, dir string, priority int) error {
+	return Mounts().MountDir(name, dir, priority)
+}
+
+// MountZip() mounts a zip archive into the game's virtual filesystem.
+func MountZip(name, path string, priority int) error {
+	return Mounts().MountZip(name, path, priority)
+}
+
+// Conflicts() returns every file in the game's virtual filesystem that's
+// provided by more than one mount, and logs each one at debug level.
+func Conflicts() []vfs.Conflict {
+	conflicts, err := Mounts().Conflicts(".")
+	if err != nil {
+		Error(err)
+		return nil
+	}
+	for _, c := range conflicts {
+		Debugf("%s is provided by %v", c.Path, c.Mounts)
+	}
+	return conflicts
+}
@@ -6,20 +6,26 @@ import (
 )
 
 var (
-	_display      *allegro.Display    // the display window
-	_displayIcons []*allegro.Bitmap   // icons used in the display
-	_eventQueue   *allegro.EventQueue // the global event queue
-	_fpsTimer     *allegro.Timer      // the FPS timer; each tick signals a new frame
+	_display      *allegro.Display     // the display window
+	_displayIcons []*allegro.Bitmap    // icons used in the display
+	_eventQueue   *allegro.EventQueue  // the global event queue
+	_fpsTimer     *allegro.Timer       // the FPS timer; each tick signals a new frame
+	_taskSource   *allegro.EventSource // signaled whenever a task is queued with RunOnMain()
 	_state        stateStack
 	_stateMap     map[StateID]*gameState
 
-	_processes   map[*gameState][]interface{} // an internal list of running processes
+	_processes   map[*gameState][]*Process // an internal list of running processes
 	_actors      map[*gameState][]interface{}
 	_actorLayers map[*gameState]map[uint][]interface{}
 	_actorStates map[interface{}]interface{}
+	_cameras     map[*gameState][]*Camera
 
-	_messengers map[interface{}]chan interface{} // an internal map from process to message channel
-	_atexit     []func()
+	_actorLayer   map[interface{}]uint // the layer that each actor was added to
+	_actorCulling map[interface{}]CullPolicy
+	_layerCulling map[*gameState]map[uint]CullPolicy
+
+	_handles map[interface{}]*Process // an internal map from process value to its handle
+	_atexit  []func()
 
 	_actorsMutex  sync.Mutex
 	_processMutex sync.Mutex // a mutex used to protect _processes
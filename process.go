@@ -1,48 +1,434 @@
 package allegory
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"github.com/dradtke/allegory/bus"
+	"sync"
+	"sync/atomic"
 )
 
-// NotifyProcess() sends an arbitrary message to a process.
-func NotifyProcess(proc interface{}, msg interface{}) {
-	defer func() {
-		// don't let closed channels kill the program
-		recover()
-	}()
-	if ch, ok := _messengers[proc]; ok {
-		ch <- msg
+var (
+	NoSuchProcess = errors.New("no such process is running")
+)
+
+// ProcessEnded is returned when trying to send a message
+// to a process that is no longer running.
+type ProcessEnded struct {
+	ID     ProcessID
+	Status ProcessStatus
+}
+
+func (e *ProcessEnded) Error() string {
+	switch e.Status {
+	case ProcessFinished, ProcessFailed:
+		return fmt.Sprintf("process %d has already %s", e.ID, e.Status)
+	case ProcessKilled:
+		return fmt.Sprintf("process %d was already killed", e.ID)
+	}
+	return fmt.Sprintf("process %d has already ended (%s)", e.ID, e.Status)
+}
+
+// ProcessID uniquely identifies a process for as long as the game is running.
+type ProcessID uint64
+
+// ProcessStatus describes where a process is in its lifecycle.
+type ProcessStatus int
+
+const (
+	// ProcessRunning means that the process hasn't ended yet.
+	ProcessRunning ProcessStatus = iota
+
+	// ProcessFinished means that the process ended on its own.
+	ProcessFinished
+
+	// ProcessFailed means that the process ended with an error,
+	// either during initialization or while running.
+	ProcessFailed
+
+	// ProcessKilled means that the process was told to quit.
+	ProcessKilled
+)
+
+func (s ProcessStatus) String() string {
+	switch s {
+	case ProcessRunning:
+		return "running"
+	case ProcessFinished:
+		return "finished"
+	case ProcessFailed:
+		return "failed"
+	case ProcessKilled:
+		return "killed"
+	}
+	return fmt.Sprintf("ProcessStatus(%d)", int(s))
+}
+
+// Process is a handle to a process that was started with RunProcess().
+// It can be passed anywhere that the process value itself is accepted.
+type Process struct {
+	id    ProcessID
+	value interface{}
+	state *gameState
+	async bool
+	ch    chan interface{} // delivers messages to asynchronous processes
+	done  chan struct{}
+
+	// These are only touched by whichever thread runs the process.
+	carryOn    bool // should the process kick off its successor, if any?
+	stepping   bool // is a synchronous process in the middle of step()?
+	noticed    bool // was it sent a notice while stepping?
+	exitStatus ProcessStatus
+	exitErr    error
+
+	mutex   sync.Mutex
+	mailbox []interface{} // messages waiting for a synchronous process
+	status  ProcessStatus
+	err     error
+	cover   CoverPolicy
+	paused  bool
+	closed  bool // has done been closed?
+
+	finishing bool // has finish() been called?
+}
+
+var _processIdCounter uint64
+
+func newProcess(value interface{}, async bool) *Process {
+	p := &Process{
+		id:         ProcessID(atomic.AddUint64(&_processIdCounter, 1)),
+		value:      value,
+		async:      async,
+		done:       make(chan struct{}),
+		carryOn:    true,
+		exitStatus: ProcessFinished,
+	}
+	if async {
+		p.ch = make(chan interface{})
+	}
+	return p
+}
+
+// ID() returns the process's unique id.
+func (p *Process) ID() ProcessID {
+	return p.id
+}
+
+// Value() returns the process value that was passed to RunProcess().
+func (p *Process) Value() interface{} {
+	return p.value
+}
+
+// Status() returns the process's current status.
+func (p *Process) Status() ProcessStatus {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.status
+}
+
+// Err() returns the error that caused the process to fail, if any.
+func (p *Process) Err() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.err
+}
+
+// Done() returns a channel that's closed once the process has ended
+// and cleaned up.
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait() blocks until the process has ended, then returns
+// the error that caused it to fail, if any.
+func (p *Process) Wait() error {
+	<-p.done
+	return p.Err()
+}
+
+// Async() returns true if the process runs in its own goroutine.
+func (p *Process) Async() bool {
+	return p.async
+}
+
+// end() records the process's final status and wakes up anyone
+// waiting on it. It returns false if the process had already ended,
+// which happens when an abandoned process finally gets around to quitting.
+func (p *Process) end(status ProcessStatus, err error) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return false
+	}
+	p.status, p.err = status, err
+	p.mailbox = nil
+	p.closed = true
+	close(p.done)
+	return true
+}
+
+// claim() marks the process as finishing, returning false if it
+// already was or has already ended, so that it's only finished once.
+func (p *Process) claim() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed || p.finishing {
+		return false
+	}
+	p.finishing = true
+	return true
+}
+
+// ended() returns true if the process has ended.
+func (p *Process) ended() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// endedError() returns an error describing how the process ended.
+func (p *Process) endedError() error {
+	return &ProcessEnded{p.id, p.Status()}
+}
+
+// post() adds a message to a synchronous process's mailbox,
+// returning false if the process has already ended.
+func (p *Process) post(msg interface{}) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.status != ProcessRunning {
+		return false
+	}
+	p.mailbox = append(p.mailbox, msg)
+	return true
+}
+
+// nextMail() takes the next message from a synchronous
+// process's mailbox, returning false if it's empty.
+func (p *Process) nextMail() (interface{}, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.mailbox) == 0 {
+		return nil, false
+	}
+	msg := p.mailbox[0]
+	p.mailbox = p.mailbox[1:]
+	return msg, true
+}
+
+// handle() dispatches a single message to the process,
+// returning false once the process has ended.
+func (p *Process) handle(msg interface{}) bool {
+	proc := p.value
+	switch msg.(type) {
+	case *quit:
+		p.carryOn = false
+		p.exitStatus = ProcessKilled
+		return false
+
+	case *tick:
+		if tickFn := tickFunc(proc); tickFn != nil {
+			alive, err := tickFn()
+			if err != nil {
+				p.fail(err)
+				return false
+			}
+			return alive
+		}
+
+	default:
+		if err := deliver(proc, msg); err != nil {
+			p.fail(fmt.Errorf("error handling %T message: %s", msg, err.Error()))
+			return false
+		}
+	}
+	return true
+}
+
+// fail() records an error that caused the process to end.
+func (p *Process) fail(err error) {
+	p.carryOn = false
+	p.exitStatus, p.exitErr = ProcessFailed, err
+	reportFailure(p.value, err)
+}
+
+// step() runs a synchronous process for one tick, first delivering
+// any messages that are waiting for it.
+func (p *Process) step() {
+	if p.ended() {
+		return
+	}
+	p.stepping = true
+	alive := p.deliverMail() && p.handle(&tick{})
+	p.stepping = false
+	if alive && p.noticed {
+		// It was told something while it was busy, such as to
+		// quit because it changed the state from its own tick.
+		alive = p.deliverMail()
+	}
+	p.noticed = false
+	if !alive {
+		p.finish()
+	}
+}
+
+// flush() delivers a synchronous process's waiting messages
+// without ticking it.
+func (p *Process) flush() {
+	if p.ended() {
+		return
+	}
+	if !p.deliverMail() {
+		p.finish()
+	}
+}
+
+// deliverMail() delivers a synchronous process's waiting messages in
+// order, including any that are sent while it's handling them. It
+// returns false once the process has ended.
+func (p *Process) deliverMail() bool {
+	for {
+		msg, ok := p.nextMail()
+		if !ok {
+			return true
+		}
+		if !p.handle(msg) || p.ended() {
+			return false
+		}
+	}
+}
+
+// finish() cleans up after a process that has ended, kicks off its
+// successor if it has one, and wakes up anyone waiting on it. It only
+// does anything the first time it's called, and not at all if the
+// process has already ended.
+func (p *Process) finish() {
+	if !p.claim() {
+		return
+	}
+	proc := p.value
+
+	if cleanupFn := cleanupFunc(proc); cleanupFn != nil {
+		cleanupFn()
+	}
+
+	p.remove()
+	if !p.end(p.exitStatus, p.exitErr) {
+		// the process was abandoned, so don't start anything new
+		return
+	}
+
+	if proc, ok := proc.(Continuable); p.carryOn && ok && stateRunning(p.state) {
+		if next := proc.Next(); next != nil {
+			start(next, p.state, p.async)
+		}
+	}
+}
+
+// stateRunning() returns true if the state hasn't been popped,
+// so that processes can still be started in it.
+func stateRunning(state *gameState) bool {
+	_processMutex.Lock()
+	defer _processMutex.Unlock()
+	_, ok := _processes[state]
+	return ok
+}
+
+// remove() takes the process out of its state's list.
+func (p *Process) remove() {
+	_processMutex.Lock()
+	defer _processMutex.Unlock()
+	for i, process := range _processes[p.state] {
+		if process == p {
+			_processes[p.state] = append(_processes[p.state][:i], _processes[p.state][i+1:]...)
+			break
+		}
+	}
+	if _handles[p.value] == p {
+		delete(_handles, p.value)
+	}
+}
+
+// findProcess() returns the handle for a process, which may either
+// be a handle already or the value that was passed to RunProcess().
+func findProcess(proc interface{}) *Process {
+	if p, ok := proc.(*Process); ok {
+		return p
+	}
+	_processMutex.Lock()
+	defer _processMutex.Unlock()
+	return _handles[proc]
+}
+
+// Processes() returns handles for all of the current
+// state's running processes.
+func Processes() []*Process {
+	return processesIn(_state.Current())
+}
+
+// processesIn() returns handles for all of a state's running processes.
+func processesIn(state *gameState) []*Process {
+	_processMutex.Lock()
+	defer _processMutex.Unlock()
+	return append([]*Process(nil), _processes[state]...)
+}
+
+// NotifyProcess() sends an arbitrary message to a process. Messages
+// for synchronous processes are delivered on the main thread just
+// before their next tick. If the process has already ended, an error
+// of type ProcessEnded is returned, or NoSuchProcess if proc isn't a
+// handle and there's no record of it.
+func NotifyProcess(proc interface{}, msg interface{}) error {
+	p := findProcess(proc)
+	if p == nil {
+		return NoSuchProcess
+	}
+	if !p.async {
+		if !p.post(msg) {
+			return p.endedError()
+		}
+		return nil
+	}
+	select {
+	case p.ch <- msg:
+		return nil
+	case <-p.done:
+		return p.endedError()
 	}
 }
 
 // NotifyAllProcesses() sends an arbitrary message to all running
 // processes.
 func NotifyAllProcesses(msg interface{}) {
-	for _, process := range _processes[_state.Current()] {
+	for _, process := range Processes() {
 		NotifyProcess(process, msg)
 	}
 }
 
 // NotifyWhere() sends an arbitrary message to each running process
-// that matches the filter criteria.
+// that matches the filter criteria. The filter is passed the
+// process value, not its handle.
 func NotifyWhere(msg interface{}, filter func(interface{}) bool) {
-	for _, process := range _processes[_state.Current()] {
-		if filter(process) {
+	for _, process := range Processes() {
+		if filter(process.value) {
 			NotifyProcess(process, msg)
 		}
 	}
 }
 
 // Close() sends a Quit message to a process.
-func Close(proc interface{}) {
-	NotifyProcess(proc, &quit{})
+func Close(proc interface{}) error {
+	return NotifyProcess(proc, &quit{})
 }
 
-// RunProcess() takes a Process and kicks it off in a new
-// goroutine. That goroutine continually listens for messages
-// on its internal channel and dispatches them to the defined
-// handler, with two special cases:
+// RunProcess() takes a Process and adds it to the current state.
+// Each tick, the processes are ticked in the order they were started,
+// on the main thread, within the engine's fixed update step. Messages
+// are dispatched to the defined handler just before the tick, with
+// two special cases:
 //
 //    1. Quit messages, which cause the process to quit and
 //       clean up without kicking off additional processes.
@@ -50,101 +436,167 @@ func Close(proc interface{}) {
 //    2. Tick messages, which simply tell the process to
 //       process one frame.
 //
-func RunProcess(proc interface{}) {
-	var initFn func() error = nil
+// The returned handle can be used to check on the process's
+// status or wait for it to end.
+func RunProcess(proc interface{}) *Process {
+	return start(proc, _state.Current(), false)
+}
 
-	if proc, ok := proc.(privatelyInitializableWithFailure); ok {
-		initFn = proc.init
-	} else if proc, ok := proc.(InitializableWithFailure); ok {
-		initFn = proc.Init
+// RunProcessAsync() is like RunProcess(), but kicks the process off in
+// a new goroutine. That goroutine continually listens for messages on
+// its internal channel, so the process can react to them immediately,
+// but it must take care when touching anything shared with the rest of
+// the game. Its successor, if any, is run asynchronously as well.
+func RunProcessAsync(proc interface{}) *Process {
+	return start(proc, _state.Current(), true)
+}
+
+// start() initializes a process and adds it to the state's list.
+func start(proc interface{}, state *gameState, async bool) *Process {
+	p := newProcess(proc, async)
+	p.state = state
+	if proc, ok := proc.(Coverable); ok {
+		p.cover = proc.CoverPolicy()
 	}
 
-	if initFn != nil {
+	if initFn := initFunc(proc); initFn != nil {
 		if err := initFn(); err != nil {
-			fmt.Fprintf(os.Stderr, "error during process initialization: %s\n", err.Error())
-			return
+			reportFailure(proc, fmt.Errorf("error during process initialization: %s", err.Error()))
+			p.end(ProcessFailed, err)
+			return p
 		}
 	}
 
-    cur := _state.Current()
-	ch := make(chan interface{})
-	_messengers[proc] = ch
 	_processMutex.Lock()
-	_processes[cur] = append(_processes[cur], proc)
+	_handles[proc] = p
+	_processes[state] = append(_processes[state], p)
 	_processMutex.Unlock()
 
-	go func(cur *gameState) {
-		defer func() {
-			_processMutex.Lock()
-			for i, process := range _processes[cur] {
-				if process == proc {
-					_processes[cur] = append(_processes[cur][:i], _processes[cur][i+1:]...)
-					break
-				}
+	if async {
+		go func() {
+			for p.handle(<-p.ch) {
 			}
-			_processMutex.Unlock()
-			delete(_messengers, proc)
-			close(ch)
+			p.finish()
 		}()
+	}
 
-		var (
-			alive   bool  = true // is the process running?
-			carryOn bool  = true // should the process kick off its successor, if any?
-			err     error = nil
-		)
-
-		for alive {
-			switch msg := <-ch; msg.(type) {
-			case *quit:
-				alive = false
-				carryOn = false
-
-			case *tick:
-				var tickFn func() (bool, error) = nil
-
-				if proc, ok := proc.(privatelyTickable); ok {
-					tickFn = proc.tick
-				} else if proc, ok := proc.(Tickable); ok {
-					tickFn = proc.Tick
-				}
-
-				if tickFn != nil {
-					if alive, err = tickFn(); err != nil {
-						alive = false
-						carryOn = false
-						fmt.Fprintf(os.Stderr, "Process exited with error message '%s'\n", err.Error())
-					}
-				}
-
-			default:
-				var handleMessageFn func(msg interface{}) error = nil
-
-				if proc, ok := proc.(privatelyMessagable); ok {
-					handleMessageFn = proc.handleMessage
-				} else if proc, ok := proc.(Messagable); ok {
-					handleMessageFn = proc.HandleMessage
-				}
-
-				if handleMessageFn != nil {
-					if err := handleMessageFn(msg); err != nil {
-						alive = false
-						carryOn = false
-						fmt.Fprintf(os.Stderr, "Process handled %v with error message '%s'\n", err.Error())
-					}
-				}
+	return p
+}
+
+// tickProcesses() ticks each of the current state's processes in
+// the order that they were started, after ticking any processes in
+// covered states that keep running while covered.
+func tickProcesses() {
+	for _, state := range _state.Covered() {
+		for _, p := range processesIn(state) {
+			if p.CoverPolicy() == TickWhenCovered {
+				p.tick()
 			}
 		}
-
-		if proc, ok := proc.(Cleanupable); ok {
-			proc.Cleanup()
+	}
+	for _, p := range Processes() {
+		if !p.Paused() {
+			p.tick()
 		}
+	}
+}
+
+// tick() runs synchronous processes right away,
+// and sends asynchronous ones a tick message.
+func (p *Process) tick() {
+	if p.async {
+		NotifyProcess(p, &tick{})
+	} else {
+		p.step()
+	}
+}
+
+// reportFailure() logs a process failure and signals it on the bus.
+// Since asynchronous processes can fail too, the signal is always
+// sent from the main thread.
+func reportFailure(proc interface{}, err error) {
+	Errorf("process %T failed: %s", proc, err.Error())
+	RunOnMain(func() {
+		bus.Signal(bus.ProcessFailedEvent, proc, err)
+	})
+}
+
+// recoverError() is deferred by the functions below to turn
+// a panic inside of a process into an error.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		*err = errorize(r)
+	}
+}
 
-		if proc, ok := proc.(Continuable); carryOn && ok {
-			if next := proc.Next(); next != nil {
-				RunProcess(next)
+// initFunc() returns a process's initialization method, if it has one.
+func initFunc(proc interface{}) func() error {
+	var f func() error
+	if proc, ok := proc.(privatelyInitializableWithFailure); ok {
+		f = proc.init
+	} else if proc, ok := proc.(InitializableWithFailure); ok {
+		f = proc.Init
+	} else {
+		return nil
+	}
+	return func() (err error) {
+		defer recoverError(&err)
+		return f()
+	}
+}
+
+// tickFunc() returns a process's tick method, if it has one.
+func tickFunc(proc interface{}) func() (bool, error) {
+	var f func() (bool, error)
+	if proc, ok := proc.(privatelyTickable); ok {
+		f = proc.tick
+	} else if proc, ok := proc.(Tickable); ok {
+		f = proc.Tick
+	} else {
+		return nil
+	}
+	return func() (alive bool, err error) {
+		defer recoverError(&err)
+		return f()
+	}
+}
+
+// messageFunc() returns a process's message handler, if it has one.
+func messageFunc(proc interface{}) func(msg interface{}) error {
+	var f func(msg interface{}) error
+	if p, ok := proc.(privatelyMessagable); ok {
+		f = p.handleMessage
+	} else if p, ok := proc.(Messagable); ok {
+		f = p.HandleMessage
+	} else {
+		return nil
+	}
+	return func(msg interface{}) (err error) {
+		defer recoverError(&err)
+		return f(msg)
+	}
+}
+
+// cleanupFunc() returns a process's cleanup method, if it has one.
+func cleanupFunc(proc interface{}) func() {
+	var f func()
+	if p, ok := proc.(privatelyCleanupable); ok {
+		f = p.cleanup
+	} else if p, ok := proc.(Cleanupable); ok {
+		f = p.Cleanup
+	} else {
+		return nil
+	}
+	return func() {
+		var err error
+		defer func() {
+			if err != nil {
+				reportFailure(proc, err)
 			}
-		}
-	}(cur)
+		}()
+		defer recoverError(&err)
+		f()
+	}
 }
 
 type tick struct{}
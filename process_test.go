@@ -0,0 +1,91 @@
+package allegory
+
+import (
+	"container/list"
+	"github.com/dradtke/go-allegro/allegro"
+	"testing"
+)
+
+// resetStates() sets up an empty state stack without
+// initializing Allegro.
+func resetStates() {
+	_state = stateStack{list.New()}
+	_processes = make(map[*gameState][]*Process)
+	_actors = make(map[*gameState][]interface{})
+	_actorLayers = make(map[*gameState]map[uint][]interface{})
+	_actorStates = make(map[interface{}]interface{})
+	_cameras = make(map[*gameState][]*Camera)
+	_actorLayer = make(map[interface{}]uint)
+	_actorCulling = make(map[interface{}]CullPolicy)
+	_layerCulling = make(map[*gameState]map[uint]CullPolicy)
+	_handles = make(map[interface{}]*Process)
+	_pressedKeys = make(map[allegro.KeyCode]bool)
+}
+
+// countedDelay is a DelayProcess that counts its cleanups.
+type countedDelay struct {
+	*DelayProcess
+	cleanups int
+}
+
+func (p *countedDelay) Cleanup() {
+	p.cleanups++
+}
+
+func TestChangeStateFromOwnTick(t *testing.T) {
+	resetStates()
+	DefState("a")
+	DefState("b")
+	PushState("a")
+
+	proc := &countedDelay{DelayProcess: &DelayProcess{Delay: 1, Activate: func() {
+		NewStateNow("b")
+	}}}
+	p := RunProcess(proc)
+	tickProcesses()
+
+	if proc.cleanups != 1 {
+		t.Errorf("cleanups = %d, want 1", proc.cleanups)
+	}
+	if !p.ended() {
+		t.Fatal("process didn't end")
+	}
+	if status := p.Status(); status != ProcessFinished {
+		t.Errorf("status = %s, want %s", status, ProcessFinished)
+	}
+	if len(Processes()) != 0 {
+		t.Errorf("new state has %d processes, want 0", len(Processes()))
+	}
+}
+
+// stateChanger changes the state from its tick, but keeps running.
+type stateChanger struct {
+	cleanups int
+}
+
+func (p *stateChanger) tick() (bool, error) {
+	NewStateNow("b")
+	return true, nil
+}
+
+func (p *stateChanger) Cleanup() {
+	p.cleanups++
+}
+
+func TestQuitAfterChangingStateFromOwnTick(t *testing.T) {
+	resetStates()
+	DefState("a")
+	DefState("b")
+	PushState("a")
+
+	proc := new(stateChanger)
+	p := RunProcess(proc)
+	tickProcesses()
+
+	if proc.cleanups != 1 {
+		t.Errorf("cleanups = %d, want 1", proc.cleanups)
+	}
+	if status := p.Status(); status != ProcessKilled {
+		t.Errorf("status = %s, want %s", status, ProcessKilled)
+	}
+}
@@ -2,12 +2,15 @@ package allegory
 
 import (
 	"errors"
+	"github.com/dradtke/allegory/bus"
+	"github.com/dradtke/allegory/cache"
+	"github.com/dradtke/allegory/easing"
 	"github.com/dradtke/go-allegro/allegro"
-	"math"
 )
 
 var (
-	NoFrames = errors.New("no frames were provided for this animation")
+	NoFrames      = errors.New("no frames were provided for this animation")
+	NoTweenTarget = errors.New("no target was provided for this tween")
 )
 
 /* -- DelayProcess -- */
@@ -46,32 +49,175 @@ func (p *DelayProcess) Next() interface{} {
 	return p.Successor
 }
 
+/* -- TweenProcess -- */
+
+// RepeatForever can be used as a TweenProcess's Repeat value
+// to make it play until it's closed.
+const RepeatForever = -1
+
+// TweenProcess is a process that gradually changes a value
+// from one number to another over a set amount of time.
+type TweenProcess struct {
+	timer    uint
+	plays    int
+	backward bool
+
+	// Target is the value to change.
+	Target *float32
+
+	// Set, if provided, is called with each new value
+	// instead of writing it to Target.
+	Set func(value float32)
+
+	// Get, if provided, is called when the tween starts, and
+	// the value that it returns is used in place of From.
+	Get func() float32
+
+	// From and To are the starting and ending values.
+	From, To float32
+
+	// Duration is the number of ticks that it takes
+	// to get from one value to the other.
+	Duration uint
+
+	// Ease is the curve that the value follows. If it isn't
+	// provided, the value changes at a constant rate.
+	Ease easing.Func
+
+	// Repeat is the number of additional times to play the tween.
+	// Use RepeatForever to keep playing until it's closed.
+	Repeat int
+
+	// Yoyo causes every other repetition to play in reverse,
+	// going from To back to From.
+	Yoyo bool
+
+	// OnRepeat is called each time the tween starts over.
+	OnRepeat func()
+
+	// OnComplete is called once the tween finishes playing,
+	// but not if it's closed early.
+	OnComplete func()
+
+	// Successor is the process to kick off after OnComplete
+	// is called.
+	Successor interface{}
+}
+
+func (p *TweenProcess) init() error {
+	if p.Target == nil && p.Set == nil {
+		return NoTweenTarget
+	}
+	if p.Get != nil {
+		p.From = p.Get()
+	}
+	p.set(p.From)
+	return nil
+}
+
+func (p *TweenProcess) tick() (bool, error) {
+	p.timer++
+	t := float32(1)
+	if p.timer < p.Duration {
+		t = float32(p.timer) / float32(p.Duration)
+	}
+	if p.backward {
+		t = 1 - t
+	}
+	ease := p.Ease
+	if ease == nil {
+		ease = easing.Linear
+	}
+	p.set(p.From + (p.To-p.From)*ease(t))
+
+	if p.timer < p.Duration {
+		return true, nil
+	}
+	p.plays++
+	if p.Repeat != RepeatForever && p.plays > p.Repeat {
+		if p.OnComplete != nil {
+			p.OnComplete()
+		}
+		return false, nil
+	}
+	p.timer = 0
+	if p.Yoyo {
+		p.backward = !p.backward
+	}
+	if p.OnRepeat != nil {
+		p.OnRepeat()
+	}
+	return true, nil
+}
+
+// set() updates the tweened value.
+func (p *TweenProcess) set(value float32) {
+	if p.Set != nil {
+		p.Set(value)
+	} else {
+		*p.Target = value
+	}
+}
+
+// Next() returns a reference to the process to run once
+// the tween finishes.
+func (p *TweenProcess) Next() interface{} {
+	return p.Successor
+}
+
 /* -- AnimationProcess -- */
 
+// AnimationProcess is a process that plays an animation clip,
+// or a list of frames, one frame after another.
 type AnimationProcess struct {
-	timer        uint
+	clip         *cache.AnimationClip
+	index, dir   int
+	elapsed      float32
+	plays        int
+	bounced      bool
 	currentFrame *allegro.Bitmap
 
-	// Step is the number of ticks between frames.
+	// Clip is the clip to play. If it isn't provided,
+	// one is created from Frames and Step.
+	Clip *cache.AnimationClip
+
+	// Step is the number of ticks between frames
+	// when playing Frames.
 	Step uint
 
 	// Frames is a list of bitmaps, one for each frame.
 	Frames []*allegro.Bitmap
 
-	Repeat, Paused, Reversed bool
+	// Speed scales how quickly the animation plays, e.g. 2 plays
+	// it twice as fast. A value of 0 is treated as 1.
+	Speed float32
+
+	// Times is the number of times to play the clip before finishing;
+	// a ping-pong counts as one play once it's back to the start. Use
+	// RepeatForever to keep playing until it's closed. A value of 0
+	// plays a PlayOnce clip once and any other clip forever.
+	Times int
+
+	// Repeat loops Frames instead of playing them once.
+	// It has no effect when playing a Clip.
+	Repeat bool
+
+	Paused, Reversed bool
 }
 
 func (p *AnimationProcess) init() error {
-	if p.Frames == nil || len(p.Frames) == 0 {
-		return NoFrames
+	p.clip = p.Clip
+	if p.clip == nil {
+		mode := cache.PlayOnce
+		if p.Repeat {
+			mode = cache.PlayLoop
+		}
+		p.clip = cache.NewClip("", p.Step, mode, p.Frames...)
 	}
-	if !p.Repeat {
-		p.currentFrame = p.Frames[0]
-	} else {
-		index := len(p.Frames) - 1
-		p.timer = uint(index) * p.Step
-		p.currentFrame = p.Frames[index]
+	if len(p.clip.Frames) == 0 {
+		return NoFrames
 	}
+	p.reset()
 	return nil
 }
 
@@ -79,28 +225,88 @@ func (p *AnimationProcess) tick() (bool, error) {
 	if p.Paused {
 		return true, nil
 	}
-	if !p.Reversed {
-		p.timer++
-	} else {
-		p.timer--
+	speed := p.Speed
+	if speed == 0 {
+		speed = 1
 	}
-	index := int(math.Floor(float64(p.timer) / float64(p.Step)))
-	if index >= len(p.Frames) {
-		if p.Repeat {
-			p.timer, index = 0, 0
-		} else {
+	p.elapsed += speed
+	for p.elapsed >= p.duration() {
+		p.elapsed -= p.duration()
+		if !p.advance() {
 			return false, nil
 		}
-	} else if index < 0 {
-		if p.Repeat {
-			index = len(p.Frames) - 1
-			p.timer = uint(index) * p.Step
+		p.show()
+	}
+	return true, nil
+}
+
+// reset() goes back to the start of the clip.
+func (p *AnimationProcess) reset() {
+	p.elapsed, p.plays, p.bounced = 0, 0, false
+	p.index, p.dir = 0, 1
+	if p.Reversed {
+		p.index, p.dir = len(p.clip.Frames)-1, -1
+	}
+	p.show()
+}
+
+// duration() returns the number of ticks to show the current frame for.
+func (p *AnimationProcess) duration() float32 {
+	if d := p.clip.Frames[p.index].Duration; d > 0 {
+		return float32(d)
+	}
+	return 1
+}
+
+// advance() moves on to the next frame, returning false
+// if the animation has finished.
+func (p *AnimationProcess) advance() bool {
+	last := len(p.clip.Frames) - 1
+	if next := p.index + p.dir; next >= 0 && next <= last {
+		p.index = next
+		return true
+	}
+	if p.clip.Mode == cache.PlayPingPong && !p.bounced && last > 0 {
+		p.bounced, p.dir = true, -p.dir
+		p.index += p.dir
+		return true
+	}
+
+	p.plays++
+	times := p.Times
+	if times == 0 {
+		if p.clip.Mode == cache.PlayOnce {
+			times = 1
 		} else {
-			return false, nil
+			times = RepeatForever
 		}
 	}
-	p.currentFrame = p.Frames[index]
-	return true, nil
+	if times != RepeatForever && p.plays >= times {
+		return false
+	}
+
+	if p.clip.Mode == cache.PlayPingPong && last > 0 {
+		p.bounced, p.dir = false, -p.dir
+		p.index += p.dir
+	} else if p.dir > 0 {
+		p.index = 0
+	} else {
+		p.index = last
+	}
+	return true
+}
+
+// show() makes the current frame visible, signaling its event if it has
+// one. The signal is sent from the main thread, since the animation may
+// be running asynchronously.
+func (p *AnimationProcess) show() {
+	frame := p.clip.Frames[p.index]
+	p.currentFrame = frame.Image
+	if event := frame.Event; event != "" {
+		RunOnMain(func() {
+			bus.Signal(bus.AnimationFrameEvent, p, event)
+		})
+	}
 }
 
 func (p *AnimationProcess) handleMessage(msg interface{}) error {
@@ -110,11 +316,16 @@ func (p *AnimationProcess) handleMessage(msg interface{}) error {
 	case *ResumeAnimation:
 		p.Paused = false
 	case *ResetAnimation:
-		p.timer = 0
+		p.reset()
 	}
 	return nil
 }
 
+// CurrentIndex() returns the index of the current frame in the clip.
+func (p *AnimationProcess) CurrentIndex() int {
+	return p.index
+}
+
 // CurrentFrame() returns a reference to the current image in the animation
 // sequence. This should be used in your Render() method to draw the
 // animation to the screen:
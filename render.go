@@ -0,0 +1,78 @@
+package allegory
+
+import (
+	"github.com/dradtke/go-allegro/allegro"
+)
+
+// renderActors() draws the current state's actor layers, once for each
+// of its cameras, or directly onto the display if it doesn't have any.
+func renderActors(delta float32) {
+	cameras := Cameras()
+	if len(cameras) == 0 {
+		view := Rect{0, 0, float32(_display.Width()), float32(_display.Height())}
+		renderLayers(delta, AllLayers, view)
+		return
+	}
+
+	var t allegro.Transform
+	for _, cam := range cameras {
+		vp := cam.Viewport
+		allegro.SetClippingRectangle(int(vp.X), int(vp.Y), int(vp.W), int(vp.H))
+		t.Identity()
+		t.Translate(vp.X-cam.X, vp.Y-cam.Y)
+		t.Use()
+		renderLayers(delta, cam.Layers, cam.View())
+	}
+	t.Identity()
+	t.Use()
+	allegro.ResetClippingRectangle()
+}
+
+// renderLayers() draws every actor on the layers in mask
+// that can be seen within view.
+func renderLayers(delta float32, mask LayerMask, view Rect) {
+	actorLayers := _state.ActorLayers()
+	for i := uint(0); i <= _highestLayer; i++ {
+		if !mask.Has(i) {
+			continue
+		}
+		layer, ok := actorLayers[i]
+		if !ok {
+			continue
+		}
+		for _, actor := range layer {
+			if hidden(actor, view) {
+				continue
+			}
+			renderActor(actor, delta)
+		}
+	}
+}
+
+// renderActor() renders an actor using its state's Render() method
+// if it has one, falling back to the actor's own.
+func renderActor(actor interface{}, delta float32) {
+	if state, ok := _actorStates[actor]; ok {
+		if state, ok := state.(Renderable); ok {
+			state.Render(delta)
+			return
+		}
+	}
+	if actor, ok := actor.(Renderable); ok {
+		actor.Render(delta)
+	}
+}
+
+// inView() returns true if the actor's bounds overlap view. Actors
+// without bounds, or with an empty size, are always considered visible.
+func inView(actor interface{}, view Rect) bool {
+	bounded, ok := actor.(Bounded)
+	if !ok {
+		return true
+	}
+	bounds := bounded.Bounds()
+	if bounds.W <= 0 || bounds.H <= 0 {
+		return true
+	}
+	return bounds.Intersects(view)
+}
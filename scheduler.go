@@ -0,0 +1,164 @@
+package allegory
+
+import (
+	"github.com/dradtke/allegory/config"
+	"math"
+	"sync"
+)
+
+var (
+	_gameTime      uint64       // the number of fixed steps since the game started
+	_schedule      []*Scheduled // functions waiting to be run
+	_scheduleMutex sync.Mutex
+)
+
+// Scheduled is a function that's been scheduled to run on the engine clock.
+//
+// Scheduled functions always run on the main thread, within the fixed
+// update step and before processes are ticked, but only while the state
+// that they were scheduled in is the current one. Time spent covered by
+// another state doesn't count towards the delay, and they're cancelled
+// when the state is popped.
+type Scheduled struct {
+	state     *gameState
+	f         func()
+	remaining uint64 // ticks left before running, unless absolute
+	absolute  bool   // should it run at a specific game time?
+	due       uint64 // the game time to run at, if absolute
+	interval  uint64 // ticks between runs if repeating, otherwise 0
+	cancelled bool
+}
+
+// Cancel() stops the function from being run again.
+func (s *Scheduled) Cancel() {
+	_scheduleMutex.Lock()
+	defer _scheduleMutex.Unlock()
+	s.cancelled = true
+}
+
+// Pending() returns true if the function is still going to be run.
+func (s *Scheduled) Pending() bool {
+	_scheduleMutex.Lock()
+	defer _scheduleMutex.Unlock()
+	return !s.cancelled
+}
+
+// GameTime() returns the number of fixed update steps
+// since the game started.
+func GameTime() uint64 {
+	return _gameTime
+}
+
+// AfterTicks() runs f once, after the given number of ticks.
+func AfterTicks(ticks uint, f func()) *Scheduled {
+	return schedule(&Scheduled{f: f, remaining: uint64(ticks)})
+}
+
+// Every() runs f repeatedly, once every given number of ticks.
+func Every(ticks uint, f func()) *Scheduled {
+	if ticks == 0 {
+		ticks = 1
+	}
+	return schedule(&Scheduled{f: f, remaining: uint64(ticks), interval: uint64(ticks)})
+}
+
+// At() runs f once, as soon as the game time reaches gameTime.
+func At(gameTime uint64, f func()) *Scheduled {
+	return schedule(&Scheduled{f: f, absolute: true, due: gameTime})
+}
+
+// AfterSeconds() runs f once, after the given number of seconds.
+func AfterSeconds(seconds float64, f func()) *Scheduled {
+	return AfterTicks(secondsToTicks(seconds), f)
+}
+
+// EverySeconds() runs f repeatedly, once every given number of seconds.
+func EverySeconds(seconds float64, f func()) *Scheduled {
+	return Every(secondsToTicks(seconds), f)
+}
+
+// secondsToTicks() converts seconds into a number of ticks
+// at the configured frame rate.
+func secondsToTicks(seconds float64) uint {
+	if seconds <= 0 {
+		return 0
+	}
+	return uint(math.Ceil(seconds*float64(config.Fps()) - 1e-9))
+}
+
+// schedule() adds s to the schedule for the current state.
+func schedule(s *Scheduled) *Scheduled {
+	s.state = _state.Current()
+	_scheduleMutex.Lock()
+	defer _scheduleMutex.Unlock()
+	_schedule = append(_schedule, s)
+	return s
+}
+
+// runSchedule() advances the game time by one tick, and runs each of
+// the current state's scheduled functions that are due, in the order
+// they were scheduled.
+func runSchedule() {
+	_gameTime++
+	cur := _state.Current()
+
+	_scheduleMutex.Lock()
+	due := make([]*Scheduled, 0)
+	for _, s := range _schedule {
+		if s.cancelled || s.state != cur {
+			continue
+		}
+		if s.absolute {
+			if _gameTime >= s.due {
+				due = append(due, s)
+			}
+			continue
+		}
+		if s.remaining > 0 {
+			s.remaining--
+		}
+		if s.remaining == 0 {
+			due = append(due, s)
+		}
+	}
+	_scheduleMutex.Unlock()
+
+	for _, s := range due {
+		if !s.Pending() {
+			continue
+		}
+		s.f()
+		_scheduleMutex.Lock()
+		if s.interval > 0 {
+			s.remaining = s.interval
+		} else {
+			s.cancelled = true
+		}
+		_scheduleMutex.Unlock()
+	}
+
+	_scheduleMutex.Lock()
+	pending := _schedule[:0]
+	for _, s := range _schedule {
+		if !s.cancelled {
+			pending = append(pending, s)
+		}
+	}
+	_schedule = pending
+	_scheduleMutex.Unlock()
+}
+
+// unschedule() cancels everything that was scheduled in the state.
+func unschedule(state *gameState) {
+	_scheduleMutex.Lock()
+	defer _scheduleMutex.Unlock()
+	pending := _schedule[:0]
+	for _, s := range _schedule {
+		if s.state == state {
+			s.cancelled = true
+		} else {
+			pending = append(pending, s)
+		}
+	}
+	_schedule = pending
+}
@@ -0,0 +1,207 @@
+package allegory
+
+import (
+	"errors"
+	"github.com/dradtke/allegory/bus"
+	"runtime"
+)
+
+var (
+	NoScript = errors.New("no script was provided for this process")
+)
+
+// ScriptProcess is a process written as a plain function instead of
+// a state machine. The function runs in its own goroutine, but only
+// ever while the process is being ticked; each call to one of the
+// context's Wait methods hands control back to the engine until the
+// next tick. The process finishes when the function returns.
+//
+//	allegory.RunProcess(allegory.Script(func(ctx *allegory.ScriptContext) error {
+//	    door.Open()
+//	    ctx.WaitTicks(30)
+//	    ctx.WaitSignal(signals.HERO_LANDED)
+//	    return ctx.Await(&allegory.DelayProcess{Delay: 60, Activate: door.Close})
+//	}))
+//
+// If the process is closed while the function is waiting, the
+// function's goroutine exits immediately, running any deferred calls.
+//
+// Although the main thread is blocked while the script runs, the script's
+// goroutine isn't the main thread, which Allegro is locked to, so scripts
+// must not draw, create bitmaps or load anything into the cache directly.
+// Queue that work with RunOnMain() instead; RunOnMainSync() would never
+// return, since the main thread is waiting on the script.
+type ScriptProcess struct {
+	ctx *ScriptContext
+
+	// Script is the function to run.
+	Script func(ctx *ScriptContext) error
+}
+
+// Script() returns a process that runs f.
+func Script(f func(ctx *ScriptContext) error) *ScriptProcess {
+	return &ScriptProcess{Script: f}
+}
+
+func (p *ScriptProcess) init() error {
+	if p.Script == nil {
+		return NoScript
+	}
+	p.ctx = &ScriptContext{
+		resume: make(chan bool),
+		yield:  make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	return nil
+}
+
+func (p *ScriptProcess) tick() (bool, error) {
+	c := p.ctx
+	c.ticks++
+	if !c.started {
+		c.started = true
+		go c.run(p.Script)
+	} else {
+		c.resume <- true
+	}
+	select {
+	case <-c.yield:
+		return true, nil
+	case <-c.done:
+		return false, c.err
+	}
+}
+
+func (p *ScriptProcess) cleanup() {
+	c := p.ctx
+	if !c.started {
+		return
+	}
+	select {
+	case <-c.done:
+	default:
+		c.resume <- false
+		<-c.done
+	}
+}
+
+/* -- ScriptContext -- */
+
+// ScriptContext is passed to a script, and provides
+// the methods that it uses to wait.
+type ScriptContext struct {
+	resume  chan bool     // tells the script whether to keep going
+	yield   chan struct{} // tells the process that the script is waiting
+	done    chan struct{} // closed once the script has returned
+	err     error
+	started bool
+	ticks   uint
+}
+
+// run() runs the script, recording its result.
+func (c *ScriptContext) run(script func(ctx *ScriptContext) error) {
+	defer close(c.done)
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = errorize(r)
+		}
+	}()
+	c.err = script(c)
+}
+
+// wait() hands control back to the engine until the next tick.
+func (c *ScriptContext) wait() {
+	c.yield <- struct{}{}
+	if !<-c.resume {
+		runtime.Goexit()
+	}
+}
+
+// Ticks() returns the number of ticks since the script started.
+func (c *ScriptContext) Ticks() uint {
+	return c.ticks
+}
+
+// WaitTicks() waits for n ticks.
+func (c *ScriptContext) WaitTicks(n uint) {
+	for i := uint(0); i < n; i++ {
+		c.wait()
+	}
+}
+
+// WaitUntil() waits until cond returns true. It's
+// checked immediately, then once per tick.
+func (c *ScriptContext) WaitUntil(cond func() bool) {
+	for !cond() {
+		c.wait()
+	}
+}
+
+// WaitSignal() waits until the event is signaled on
+// the bus, then returns the parameters it was sent with.
+func (c *ScriptContext) WaitSignal(eventType bus.EventId) []interface{} {
+	var (
+		fired  bool
+		params []interface{}
+	)
+	l, _ := bus.ListenOnce(eventType, func(args ...interface{}) {
+		fired, params = true, args
+	})
+	// Don't leave the listener behind if the script is closed first.
+	defer l.Remove()
+	for !fired {
+		c.wait()
+	}
+	return params
+}
+
+// Await() waits for another process to end, returning the error that
+// caused it to fail, if any. proc can be a handle, a process value
+// that's already running, or a new process value, which is started
+// with RunProcess().
+func (c *ScriptContext) Await(proc interface{}) error {
+	p := findProcess(proc)
+	if p == nil {
+		p = RunProcess(proc)
+	}
+	for {
+		select {
+		case <-p.Done():
+			return p.Err()
+		default:
+			c.wait()
+		}
+	}
+}
+
+// Ask() is like allegory.Ask(), but waits for the reply a tick at a time,
+// so it works with synchronous processes too. It gives up after the given
+// number of ticks, or never if it's 0.
+func (c *ScriptContext) Ask(proc interface{}, msg interface{}, ticks uint) (interface{}, error) {
+	p := findProcess(proc)
+	if p == nil {
+		return nil, NoSuchProcess
+	}
+	r := &request{msg, make(chan response, 1)}
+	if err := NotifyProcess(p, r); err != nil {
+		return nil, err
+	}
+	for waited := uint(0); ; waited++ {
+		select {
+		case resp := <-r.reply:
+			return resp.value, resp.err
+		case <-p.Done():
+			select {
+			case resp := <-r.reply:
+				return resp.value, resp.err
+			default:
+				return nil, p.endedError()
+			}
+		default:
+		}
+		if ticks > 0 && waited >= ticks {
+			return nil, AskTimedOut
+		}
+		c.wait()
+	}
+}
@@ -0,0 +1,71 @@
+package allegory
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ProcessAbandoned = errors.New("process didn't quit in time and was abandoned")
+)
+
+// QuitProcesses() tells all of the current state's processes to quit,
+// then waits up to timeout for them to finish. Any that are still running
+// after that are abandoned: they're removed from the state, their handles
+// end with ProcessAbandoned, and they're logged and returned. It must be
+// called from the main thread.
+func QuitProcesses(timeout time.Duration) []*Process {
+	return quitProcesses(_state.Current(), timeout)
+}
+
+func quitProcesses(state *gameState, timeout time.Duration) []*Process {
+	procs := processesIn(state)
+	for _, p := range procs {
+		if p.async {
+			// don't wait on a process that's stuck in the middle of a tick
+			go NotifyProcess(p, &quit{})
+		} else {
+			p.notice(&quit{})
+		}
+	}
+	return awaitProcesses(procs, timeout)
+}
+
+// awaitProcesses() waits up to timeout for each of the processes to end,
+// then abandons and returns the ones that haven't.
+func awaitProcesses(procs []*Process, timeout time.Duration) []*Process {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	expired := false
+	stuck := make([]*Process, 0)
+	for _, p := range procs {
+		if p.stepping {
+			// It can't end until its tick returns, and
+			// it'll handle being told to quit then.
+			continue
+		}
+		if !expired {
+			select {
+			case <-p.Done():
+				continue
+			case <-timer.C:
+				expired = true
+			}
+		}
+		if !p.ended() {
+			stuck = append(stuck, p)
+		}
+	}
+	for _, p := range stuck {
+		p.abandon(timeout)
+	}
+	return stuck
+}
+
+// abandon() gives up on a process that won't quit.
+func (p *Process) abandon(timeout time.Duration) {
+	p.remove()
+	if p.end(ProcessKilled, ProcessAbandoned) {
+		Errorf("process %d (%T) didn't quit within %s and was abandoned", p.id, p.value, timeout)
+	}
+}
@@ -0,0 +1,131 @@
+package allegory
+
+import (
+	"github.com/dradtke/allegory/cache"
+	"github.com/dradtke/go-allegro/allegro"
+	"math"
+)
+
+// Sprite is an actor that draws an image, or the current frame of an
+// animation, at its interpolated position, rotation and scale. Actors
+// can embed it in place of Actor to get rendering for free.
+type Sprite struct {
+	Actor
+
+	// Image is the cache key of the image to draw.
+	Image string
+
+	// Bitmap, if set, is drawn instead of Image.
+	Bitmap *allegro.Bitmap
+
+	// Animation, if set, has its current frame drawn
+	// instead of Bitmap or Image.
+	Animation *AnimationProcess
+
+	// Animator, if set, has its current frame drawn instead of
+	// Animation, Bitmap or Image, with the previous clip faded out
+	// on top of it during a transition.
+	Animator *Animator
+
+	// OriginX and OriginY are the point within the image that is
+	// drawn at the actor's position, and that it rotates and scales
+	// around.
+	OriginX, OriginY float32
+
+	// Tint, if set, is multiplied with the color of each pixel.
+	Tint *allegro.Color
+
+	// Transparency ranges from 0, fully opaque, to 1, fully
+	// transparent; tween it towards 1 to fade the sprite out.
+	Transparency float32
+
+	// Flip determines whether the image is flipped horizontally
+	// or vertically.
+	Flip allegro.DrawFlags
+
+	Hidden bool
+}
+
+// NewSprite() creates a sprite that draws the image
+// stored in the cache under key.
+func NewSprite(key string) *Sprite {
+	return &Sprite{Image: key}
+}
+
+// Frame() returns the bitmap that the sprite currently draws,
+// or nil if there isn't one.
+func (s *Sprite) Frame() *allegro.Bitmap {
+	switch {
+	case s.Animator != nil:
+		return s.Animator.CurrentFrame()
+	case s.Animation != nil:
+		return s.Animation.CurrentFrame()
+	case s.Bitmap != nil:
+		return s.Bitmap
+	case s.Image != "":
+		if bmp, err := cache.FindImage(s.Image); err == nil {
+			return bmp
+		}
+	}
+	return nil
+}
+
+// Render() draws the sprite.
+func (s *Sprite) Render(delta float32) {
+	if s.Hidden {
+		return
+	}
+	bmp := s.Frame()
+	if bmp == nil {
+		return
+	}
+	t := s.Interpolate(delta)
+	bmp.DrawTintedScaledRotated(s.color(1), s.OriginX, s.OriginY, t.X, t.Y, t.ScaleX, t.ScaleY, t.Angle, s.Flip)
+	if s.Animator != nil {
+		if prev, opacity := s.Animator.Fading(); prev != nil {
+			prev.DrawTintedScaledRotated(s.color(opacity), s.OriginX, s.OriginY, t.X, t.Y, t.ScaleX, t.ScaleY, t.Angle, s.Flip)
+		}
+	}
+}
+
+// Bounds() returns the area covered by the sprite. If Width and
+// Height aren't set, it's calculated from the size of the current
+// frame, its origin and the actor's transform.
+func (s *Sprite) Bounds() Rect {
+	if s.Width > 0 && s.Height > 0 {
+		return s.Actor.Bounds()
+	}
+	bmp := s.Frame()
+	if bmp == nil {
+		return Rect{s.X, s.Y, 0, 0}
+	}
+	t := s.Transform()
+	var (
+		w, h       = float32(bmp.Width()), float32(bmp.Height())
+		sin, cos   = math.Sincos(float64(t.Angle))
+		minX, minY = float32(math.Inf(1)), float32(math.Inf(1))
+		maxX, maxY = float32(math.Inf(-1)), float32(math.Inf(-1))
+		corners    = [4][2]float32{{0, 0}, {w, 0}, {0, h}, {w, h}}
+	)
+	for _, c := range corners {
+		cx, cy := (c[0]-s.OriginX)*t.ScaleX, (c[1]-s.OriginY)*t.ScaleY
+		x := t.X + cx*float32(cos) - cy*float32(sin)
+		y := t.Y + cx*float32(sin) + cy*float32(cos)
+		minX, maxX = float32(math.Min(float64(minX), float64(x))), float32(math.Max(float64(maxX), float64(x)))
+		minY, maxY = float32(math.Min(float64(minY), float64(y))), float32(math.Max(float64(maxY), float64(y)))
+	}
+	return Rect{minX, minY, maxX - minX, maxY - minY}
+}
+
+// color() returns the tint to draw with, with the sprite's
+// transparency and the given extra opacity already applied.
+func (s *Sprite) color(opacity float32) allegro.Color {
+	var r, g, b, a float32 = 1, 1, 1, 1
+	if s.Tint != nil {
+		r, g, b, a = s.Tint.UnmapRGBAf()
+	}
+	a *= 1 - s.Transparency
+	a *= opacity
+	// Allegro expects premultiplied alpha by default.
+	return allegro.MapRGBAf(r*a, g*a, b*a, a)
+}
@@ -2,7 +2,9 @@ package allegory
 
 import (
 	"container/list"
+	"github.com/dradtke/allegory/config"
 	"runtime"
+	"time"
 )
 
 type StateID string
@@ -78,24 +80,39 @@ func PopState() *gameState {
 	return _state.Pop()
 }
 
-// NewStateWait() waits for all processes to finish without
-// blocking the current goroutine, then changes the game state.
+// NewStateWait() waits for all processes to finish without blocking
+// the current goroutine, then changes the game state. Processes that
+// haven't finished within the configured shutdown timeout are told to
+// quit, and abandoned if they still don't by the end of it. If the state
+// has already changed by then, nothing happens.
 func NewStateWait(stateId StateID) {
+	cur, procs := _state.Current(), Processes()
+	deadline := time.Now().Add(config.ShutdownTimeout())
 	go func() {
-		for len(_processes) > 0 {
-			runtime.Gosched()
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+	wait:
+		for _, p := range procs {
+			select {
+			case <-p.Done():
+			case <-timer.C:
+				break wait
+			}
 		}
-		NewState(stateId)
+		RunOnMain(func() {
+			if _state.Current() != cur {
+				return
+			}
+			quitProcesses(cur, time.Until(deadline))
+			NewState(stateId)
+		})
 	}()
 }
 
-// NewStateNow() tells all processes to quit,
-// waits for them to finish, then changes the game state.
+// NewStateNow() tells all processes to quit, waits up to the configured
+// shutdown timeout for them to finish, then changes the game state.
 func NewStateNow(stateId StateID) {
-	NotifyAllProcesses(&quit{})
-	for len(_processes) > 0 {
-		runtime.Gosched()
-	}
+	quitProcesses(_state.Current(), config.ShutdownTimeout())
 	NewState(stateId)
 }
 
@@ -117,26 +134,53 @@ func (s *stateStack) Current() *gameState {
 	return front.Value.(*gameState)
 }
 
-func (s *stateStack) Push(state *gameState) {
+// Push() pushes a copy of the state's definition, so that a state that's
+// on the stack more than once has its own processes, actors and cameras
+// for each entry.
+func (s *stateStack) Push(def *gameState) {
 	cur := s.Current()
 	if cur != nil {
 		//cur.OnPause()
+		coverProcesses(cur)
 	}
 
+	var state *gameState
+	if def != nil {
+		copied := *def
+		state = &copied
+	}
 	s.stack.PushFront(state)
 
 	if state != nil {
-		_processes[state] = make([]interface{}, 0)
+		_processes[state] = make([]*Process, 0)
 		_actors[state] = make([]interface{}, 0)
 		_actorLayers[state] = make(map[uint][]interface{})
+		_cameras[state] = make([]*Camera, 0)
+		_layerCulling[state] = make(map[uint]CullPolicy)
 		state.init()
 	}
 }
 
+// Pop() removes the current state, telling its processes to quit and
+// abandoning any that don't within the configured shutdown timeout, so
+// that anything waiting on them is woken up.
 func (s *stateStack) Pop() *gameState {
+	oldState, _ := s.popWithin(config.ShutdownTimeout())
+	return oldState
+}
+
+// popWithin() is like Pop(), but gives the state's processes up to
+// timeout to quit, and returns the ones that were abandoned.
+func (s *stateStack) popWithin(timeout time.Duration) (*gameState, []*Process) {
 	oldState := s.stack.Remove(s.stack.Front()).(*gameState)
 
+	var abandoned []*Process
 	if oldState != nil {
+		abandoned = quitProcesses(oldState, timeout)
+		_processMutex.Lock()
+		delete(_processes, oldState)
+		_processMutex.Unlock()
+
 		oldState.cleanup()
 
 		if actors, ok := _actors[oldState]; ok {
@@ -144,19 +188,25 @@ func (s *stateStack) Pop() *gameState {
 				if actor, ok := actor.(Cleanupable); ok {
 					actor.Cleanup()
 				}
+				delete(_actorLayer, actor)
+				delete(_actorCulling, actor)
 			}
 			delete(_actors, oldState)
 			delete(_actorLayers, oldState)
 		}
+		delete(_cameras, oldState)
+		delete(_layerCulling, oldState)
+		unschedule(oldState)
 
 		runtime.GC()
 	}
 
 	if cur := s.Current(); cur != nil {
 		//cur.OnResume()
+		uncoverProcesses(cur)
 	}
 
-	return oldState
+	return oldState, abandoned
 }
 
 func (s *stateStack) Update() {
@@ -178,11 +228,24 @@ func (s *stateStack) Render(delta float32) {
 	}
 }
 
-func (s *stateStack) Processes() []interface{} {
+// Covered() returns every state below the current one,
+// starting from the bottom of the stack.
+func (s *stateStack) Covered() []*gameState {
+	states := make([]*gameState, 0)
+	front := s.stack.Front()
+	for e := s.stack.Back(); e != nil && e != front; e = e.Prev() {
+		if state, ok := e.Value.(*gameState); ok && state != nil {
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+func (s *stateStack) Processes() []*Process {
 	if processes, ok := _processes[s.Current()]; ok && processes != nil {
 		return processes
 	}
-	return make([]interface{}, 0)
+	return make([]*Process, 0)
 }
 
 func (s *stateStack) Actors() []interface{} {
@@ -198,3 +261,17 @@ func (s *stateStack) ActorLayers() map[uint][]interface{} {
 	}
 	return make(map[uint][]interface{})
 }
+
+func (s *stateStack) Cameras() []*Camera {
+	if cameras, ok := _cameras[s.Current()]; ok && cameras != nil {
+		return cameras
+	}
+	return make([]*Camera, 0)
+}
+
+func (s *stateStack) LayerCulling() map[uint]CullPolicy {
+	if policies, ok := _layerCulling[s.Current()]; ok && policies != nil {
+		return policies
+	}
+	return make(map[uint]CullPolicy)
+}
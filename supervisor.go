@@ -0,0 +1,150 @@
+package allegory
+
+import (
+	"errors"
+)
+
+var (
+	TooManyRestarts = errors.New("supervised processes restarted too many times")
+)
+
+// RestartStrategy determines which of a supervisor's children
+// are restarted when one of them fails.
+type RestartStrategy int
+
+const (
+	// OneForOne restarts only the child that failed.
+	OneForOne RestartStrategy = iota
+
+	// OneForAll restarts every child when any of them fails.
+	OneForAll
+)
+
+// SupervisorProcess is a process that runs a set of child processes,
+// restarting them according to its strategy whenever one fails. Each
+// failure is logged and signaled on the bus with ProcessFailedEvent.
+// The supervisor finishes once all of its children have finished,
+// and fails with TooManyRestarts if they fail too often.
+type SupervisorProcess struct {
+	children []*child
+	pending  []bool // children that need to be restarted
+	restarts []uint // the ticks at which recent restarts happened
+	timer    uint
+
+	// Children is a list of functions that create each child process.
+	// They're called again every time that child is restarted.
+	Children []func() interface{}
+
+	// Strategy determines which children are restarted after a failure.
+	Strategy RestartStrategy
+
+	// MaxRestarts is the number of restarts allowed within Window
+	// ticks before the supervisor gives up. If Window is 0, the limit
+	// applies to the supervisor's whole lifetime, and if MaxRestarts
+	// is 0, there is no limit at all.
+	MaxRestarts int
+	Window      uint
+}
+
+// Supervise() returns a supervisor that runs the children
+// created by each of fs, restarting any that fail.
+func Supervise(strategy RestartStrategy, fs ...func() interface{}) *SupervisorProcess {
+	return &SupervisorProcess{Children: fs, Strategy: strategy}
+}
+
+func (p *SupervisorProcess) init() error {
+	p.children = make([]*child, len(p.Children))
+	p.pending = make([]bool, len(p.Children))
+	for i := range p.Children {
+		p.spawn(i)
+	}
+	return nil
+}
+
+func (p *SupervisorProcess) tick() (bool, error) {
+	p.timer++
+	running := false
+	for i, c := range p.children {
+		if p.pending[i] {
+			if err := p.restart(i); err != nil {
+				return false, err
+			}
+			running = true
+			continue
+		}
+		alive, err := c.tick()
+		if err != nil {
+			reportFailure(c.proc, err)
+			if err := p.restart(i); err != nil {
+				return false, err
+			}
+			running = true
+			continue
+		}
+		running = running || alive
+	}
+	return running, nil
+}
+
+func (p *SupervisorProcess) handleMessage(msg interface{}) error {
+	for i, c := range p.children {
+		if err := c.notify(msg); err != nil {
+			reportFailure(c.proc, err)
+			if err := p.restart(i); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *SupervisorProcess) cleanup() {
+	stopAll(p.children)
+}
+
+// spawn() starts a new instance of the i'th child. If it fails to
+// start, it's reported and marked to be restarted on the next tick.
+func (p *SupervisorProcess) spawn(i int) {
+	c := &child{proc: p.Children[i]()}
+	p.children[i] = c
+	p.pending[i] = false
+	if err := c.start(); err != nil {
+		reportFailure(c.proc, err)
+		p.pending[i] = true
+	}
+}
+
+// restart() restarts the children affected by the failure of the i'th
+// one, returning TooManyRestarts if the limit has been reached.
+func (p *SupervisorProcess) restart(i int) error {
+	if !p.allowRestart() {
+		stopAll(p.children)
+		return TooManyRestarts
+	}
+	switch p.Strategy {
+	case OneForAll:
+		stopAll(p.children)
+		for j := range p.children {
+			p.spawn(j)
+		}
+	default:
+		p.spawn(i)
+	}
+	return nil
+}
+
+// allowRestart() records a restart, and returns true
+// if it's within the supervisor's limit.
+func (p *SupervisorProcess) allowRestart() bool {
+	p.restarts = append(p.restarts, p.timer)
+	if p.Window > 0 {
+		recent := p.restarts[:0]
+		for _, t := range p.restarts {
+			if p.timer-t < p.Window {
+				recent = append(recent, t)
+			}
+		}
+		p.restarts = recent
+	}
+	return p.MaxRestarts == 0 || len(p.restarts) <= p.MaxRestarts
+}
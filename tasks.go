@@ -0,0 +1,47 @@
+package allegory
+
+import (
+	"sync"
+)
+
+var (
+	_tasks     []func() // functions waiting to be run on the main thread
+	_taskMutex sync.Mutex
+)
+
+// RunOnMain() queues f to be run on the main thread between frames.
+// Anything that touches Allegro's drawing functions or creates bitmaps,
+// including loading them into the cache, should be run this way when
+// called from a goroutine. It's safe to call from any goroutine and
+// returns immediately.
+func RunOnMain(f func()) {
+	_taskMutex.Lock()
+	_tasks = append(_tasks, f)
+	_taskMutex.Unlock()
+	if _taskSource != nil {
+		_taskSource.EmitUserEvent(nil)
+	}
+}
+
+// RunOnMainSync() is like RunOnMain(), but waits for f to be run and
+// returns its result. It must not be called from the main thread,
+// including from synchronous processes, or it will never return.
+func RunOnMainSync(f func() interface{}) interface{} {
+	result := make(chan interface{}, 1)
+	RunOnMain(func() {
+		result <- f()
+	})
+	return <-result
+}
+
+// runTasks() runs every function queued with RunOnMain(),
+// in the order they were queued.
+func runTasks() {
+	_taskMutex.Lock()
+	tasks := _tasks
+	_tasks = nil
+	_taskMutex.Unlock()
+	for _, f := range tasks {
+		f()
+	}
+}
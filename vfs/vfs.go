@@ -0,0 +1,426 @@
+// Package vfs provides a virtual filesystem made up of an ordered list of
+// mounted filesystems, such as the game's base data, a DLC pack and a
+// folder of user mods. Looking up a file finds it in the highest-priority
+// mount that has it, so mods can override base assets just by providing
+// a file at the same path.
+//
+// An *FS implements fs.FS, so it can be passed to cache.SetFS() to
+// make every resource lookup go through it.
+package vfs
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Suggested priorities for the usual kinds of mount, leaving
+// room in between for anything else.
+const (
+	BasePriority = 0
+	DLCPriority  = 100
+	ModPriority  = 200
+)
+
+var (
+	MountExists   = errors.New("a filesystem is already mounted with that name")
+	NoSuchMount   = errors.New("no filesystem is mounted with that name")
+	NotADirectory = errors.New("not a directory")
+)
+
+// mount is a filesystem mounted into the VFS.
+type mount struct {
+	name     string
+	fsys     fs.FS
+	priority int
+	closer   io.Closer
+}
+
+// MountInfo describes a mounted filesystem.
+type MountInfo struct {
+	Name     string
+	Priority int
+}
+
+// Entry is a file in the VFS.
+type Entry struct {
+	Path string
+
+	// Mount is the name of the mount that the file is read from.
+	Mount string
+
+	// Shadowed lists the other mounts that have a file at the same
+	// path, which is ignored, from highest to lowest priority.
+	Shadowed []string
+}
+
+// Conflict is a path that's provided by more than one mount.
+type Conflict struct {
+	Path string
+
+	// Mounts lists each mount that provides the path, from highest to
+	// lowest priority; the file in the first one is the one that's used.
+	Mounts []string
+}
+
+// FS is a virtual filesystem. The zero value is an empty
+// filesystem that's ready to use.
+type FS struct {
+	mutex  sync.RWMutex
+	mounts []*mount // from highest to lowest priority
+}
+
+// New() creates an empty virtual filesystem.
+func New() *FS {
+	return new(FS)
+}
+
+// Mount() mounts fsys under the given name. Mounts with a higher priority
+// take precedence over those with a lower one, and among mounts with the
+// same priority, the most recently mounted one does.
+func (v *FS) Mount(name string, fsys fs.FS, priority int) error {
+	return v.mount(&mount{name: name, fsys: fsys, priority: priority})
+}
+
+// MountDir() mounts a directory on the operating system's filesystem.
+func (v *FS) MountDir(name, dir string, priority int) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &fs.PathError{Op: "mount", Path: dir, Err: NotADirectory}
+	}
+	return v.Mount(name, os.DirFS(dir), priority)
+}
+
+// MountZip() mounts the contents of a zip archive. The archive
+// stays open until it's unmounted or the VFS is closed.
+func (v *FS) MountZip(name, path string, priority int) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	if err := v.mount(&mount{name: name, fsys: r, priority: priority, closer: r}); err != nil {
+		r.Close()
+		return err
+	}
+	return nil
+}
+
+// MountEach() mounts every directory and zip archive directly inside dir,
+// such as a folder of user mods, each under its own name. They all get the
+// same priority, so the one whose name sorts last takes precedence. If dir
+// doesn't exist, nothing is mounted and no error is returned. The names of
+// the new mounts are returned in the order they were mounted.
+func (v *FS) MountEach(dir string, priority int) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		switch {
+		case e.IsDir():
+			err = v.MountDir(e.Name(), p, priority)
+		case strings.EqualFold(filepath.Ext(e.Name()), ".zip"):
+			err = v.MountZip(e.Name(), p, priority)
+		default:
+			continue
+		}
+		if err != nil {
+			return names, err
+		}
+		names = append(names, e.Name())
+	}
+	return names, nil
+}
+
+// Unmount() removes the named mount, closing it if necessary.
+func (v *FS) Unmount(name string) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	for i, m := range v.mounts {
+		if m.name == name {
+			v.mounts = append(v.mounts[:i], v.mounts[i+1:]...)
+			if m.closer != nil {
+				return m.closer.Close()
+			}
+			return nil
+		}
+	}
+	return NoSuchMount
+}
+
+// Close() unmounts everything.
+func (v *FS) Close() error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	var err error
+	for _, m := range v.mounts {
+		if m.closer != nil {
+			if e := m.closer.Close(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	v.mounts = nil
+	return err
+}
+
+// Mounts() describes each mount, from highest to lowest priority.
+func (v *FS) Mounts() []MountInfo {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	infos := make([]MountInfo, len(v.mounts))
+	for i, m := range v.mounts {
+		infos[i] = MountInfo{m.name, m.priority}
+	}
+	return infos
+}
+
+// Resolve() returns the name of the mount that a file is read from.
+func (v *FS) Resolve(name string) (string, error) {
+	m, err := v.find(name)
+	if err != nil {
+		return "", err
+	}
+	return m.name, nil
+}
+
+// Open() opens a file from the highest-priority mount that has it.
+// Directories list their contents across every mount, like ReadDir().
+func (v *FS) Open(name string) (fs.File, error) {
+	m, err := v.find(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	f, err := m.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err != nil || !info.IsDir() {
+		return f, nil
+	}
+	entries, err := v.ReadDir(name)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &dir{File: f, entries: entries}, nil
+}
+
+// Stat() describes a file from the highest-priority mount that has it.
+func (v *FS) Stat(name string) (fs.FileInfo, error) {
+	m, err := v.find(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return fs.Stat(m.fsys, name)
+}
+
+// ReadFile() reads a file from the highest-priority mount that has it.
+func (v *FS) ReadFile(name string) ([]byte, error) {
+	m, err := v.find(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return fs.ReadFile(m.fsys, name)
+}
+
+// ReadDir() lists the contents of a directory across every mount
+// that has it, sorted by name. Where more than one mount has an
+// entry with the same name, the highest-priority one is used.
+func (v *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	seen := make(map[string]bool)
+	entries := make([]fs.DirEntry, 0)
+	found := false
+	for _, m := range v.mounts {
+		dir, err := fs.ReadDir(m.fsys, name)
+		if err != nil {
+			continue
+		}
+		found = true
+		for _, e := range dir {
+			if !seen[e.Name()] {
+				seen[e.Name()] = true
+				entries = append(entries, e)
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Sort(byName(entries))
+	return entries, nil
+}
+
+// List() returns every file under root, along with the mount
+// that it's read from and any mounts that it shadows.
+func (v *FS) List(root string) ([]Entry, error) {
+	paths, err := v.providers(root)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(paths))
+	for _, p := range sortedKeys(paths) {
+		mounts := paths[p]
+		entries = append(entries, Entry{Path: p, Mount: mounts[0], Shadowed: mounts[1:]})
+	}
+	return entries, nil
+}
+
+// Conflicts() returns every file under root that's provided by more than
+// one mount, which is expected for mods overriding base assets, but can
+// also point out two mods that override the same file.
+func (v *FS) Conflicts(root string) ([]Conflict, error) {
+	paths, err := v.providers(root)
+	if err != nil {
+		return nil, err
+	}
+	conflicts := make([]Conflict, 0)
+	for _, p := range sortedKeys(paths) {
+		if mounts := paths[p]; len(mounts) > 1 {
+			conflicts = append(conflicts, Conflict{p, mounts})
+		}
+	}
+	return conflicts, nil
+}
+
+// mount() adds a mount in priority order.
+func (v *FS) mount(m *mount) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	for _, existing := range v.mounts {
+		if existing.name == m.name {
+			return MountExists
+		}
+	}
+	i := sort.Search(len(v.mounts), func(i int) bool {
+		return v.mounts[i].priority <= m.priority
+	})
+	v.mounts = append(v.mounts, nil)
+	copy(v.mounts[i+1:], v.mounts[i:])
+	v.mounts[i] = m
+	return nil
+}
+
+// find() returns the highest-priority mount that has a file at name.
+func (v *FS) find(name string) (*mount, error) {
+	if !fs.ValidPath(name) {
+		return nil, fs.ErrInvalid
+	}
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	for _, m := range v.mounts {
+		if _, err := fs.Stat(m.fsys, name); err == nil {
+			return m, nil
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+// providers() maps the path of every file under root to the
+// mounts that have it, from highest to lowest priority.
+func (v *FS) providers(root string) (map[string][]string, error) {
+	if !fs.ValidPath(root) {
+		return nil, &fs.PathError{Op: "list", Path: root, Err: fs.ErrInvalid}
+	}
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	paths := make(map[string][]string)
+	for _, m := range v.mounts {
+		fs.WalkDir(m.fsys, root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if d != nil && d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !d.IsDir() {
+				paths[p] = append(paths[p], m.name)
+			}
+			return nil
+		})
+	}
+	return paths, nil
+}
+
+// ExecutableDir() returns the path of dir relative to the directory
+// containing the running executable, which is where a shipped game's
+// data usually lives, regardless of the working directory.
+func ExecutableDir(dir string) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(exe), dir), nil
+}
+
+// PackageDir() returns the directory of a Go package found in GOPATH,
+// which is handy for running a game with "go run" during development.
+func PackageDir(pkg string) (string, error) {
+	for _, dir := range filepath.SplitList(os.Getenv("GOPATH")) {
+		p := filepath.Join(dir, "src", filepath.FromSlash(pkg))
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			return p, nil
+		}
+	}
+	return "", &fs.PathError{Op: "find", Path: pkg, Err: fs.ErrNotExist}
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// dir is a directory opened from the VFS, whose
+// entries are merged from every mount.
+type dir struct {
+	fs.File
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
+
+type byName []fs.DirEntry
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byName) Less(i, j int) bool { return s[i].Name() < s[j].Name() }